@@ -0,0 +1,70 @@
+package npq
+
+import (
+	"testing"
+)
+
+func benchmarkNewParser(benchmark *testing.B, queryText string) {
+
+	benchmark.ReportAllocs()
+
+	for i := 0; i < benchmark.N; i++ {
+		NewParser(queryText).SetValue("a", 1)
+	}
+}
+
+func BenchmarkNewParserOneParameter(benchmark *testing.B) {
+	benchmarkNewParser(benchmark, "SELECT * FROM table WHERE a = :a")
+}
+
+func BenchmarkNewParserTwoParameters(benchmark *testing.B) {
+	benchmarkNewParser(benchmark, "SELECT * FROM table WHERE a = :a AND b = :b")
+}
+
+func BenchmarkNewParserFourParameters(benchmark *testing.B) {
+	benchmarkNewParser(benchmark, "SELECT * FROM table WHERE a = :a AND b = :b AND c = :c AND d = :d")
+}
+
+func BenchmarkNewParserSixteenParameters(benchmark *testing.B) {
+	benchmarkNewParser(benchmark, "INSERT INTO table VALUES (:a, :b, :c, :d, :e, :f, :g, :h, :i, :j, :k, :l, :m, :n, :o, :p)")
+}
+
+func BenchmarkResetReparse(benchmark *testing.B) {
+
+	var prsr Parser
+
+	benchmark.ReportAllocs()
+	prsr = NewParser("")
+
+	for i := 0; i < benchmark.N; i++ {
+		prsr.Reparse("SELECT * FROM table WHERE a = :a AND b = :b AND c = :c AND d = :d")
+		prsr.SetValue("a", 1)
+		prsr.Reset()
+	}
+}
+
+func BenchmarkSetValuesFromStruct(benchmark *testing.B) {
+
+	var prsr Parser
+
+	benchmark.ReportAllocs()
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :user.name AND col2 = :Manager.name")
+
+	for i := 0; i < benchmark.N; i++ {
+		prsr.SetValuesFromStruct(NestedParameterTest{})
+	}
+}
+
+func BenchmarkStructBinder(benchmark *testing.B) {
+
+	var prsr Parser
+	var bind func(p Parser, v interface{})
+
+	benchmark.ReportAllocs()
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :user.name AND col2 = :Manager.name")
+	bind, _ = StructBinder(NestedParameterTest{})
+
+	for i := 0; i < benchmark.N; i++ {
+		bind(prsr, NestedParameterTest{})
+	}
+}
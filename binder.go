@@ -0,0 +1,292 @@
+package npq
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// boundField is a struct field resolved by StructBinder, with the name of the
+// parameter it binds.
+type boundField struct {
+
+	// The indices of the field and the structs containing it, from the outermost struct.
+	index []int
+
+	// The name of the parameter the field is bound to, e.g. "User.Name".
+	name string
+
+	// The layout a time.Time value is formatted with, if any.
+	timeFormat string
+
+	// The index of the method returning the value of the field, as named by its
+	// sqlParameterSource tag, in the method set of the struct containing it, or of
+	// a pointer to that struct if [pointerSource] is set; -1 if it has none.
+	source int
+
+	// Whether the source method has a pointer receiver.
+	pointerSource bool
+}
+
+// StructBinder resolves the fields of the type of the given struct [prototype],
+// or the struct it points to, once, and returns a function which binds the fields
+// of a value of that type, or a pointer to one, to a parser in the same way as
+// SetValuesFromStruct, without resolving them again. This avoids the cost of
+// reflecting on the struct type when binding many values in a loop, e.g.
+//
+// 	bind, err := StructBinder(User{})
+//
+// 	for _, user := range users {
+// 		bind(prsr, user)
+// 		...
+// 	}
+//
+// Parameter names are read from sqlParameterName tags, and the fields of nested
+// structs are always bound to their dotted names, except for a struct nested in
+// a struct of its own type, such as the Next field of a linked list node, which
+// is bound as a single value. The methods named by sqlParameterSource tags are
+// resolved once as well, and an error is returned if one of them is invalid, in
+// the same way as by SetValuesFromStruct; a method with a pointer receiver is
+// called on a copy of a struct value. Unlike SetValuesFromStruct, the binding
+// ignores WithJSONTagFallback and WithDuplicateFieldCheck.
+//
+// The returned function panics if it is given a value of another type, since
+// that is a programming error.
+func StructBinder(prototype interface{}) (func(p Parser, v interface{}), error) {
+
+	var structType reflect.Type
+	var fields []boundField
+	var err error
+
+	structType = reflect.TypeOf(prototype)
+
+	if structType != nil && structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+
+	if structType == nil || structType.Kind() != reflect.Struct {
+		return nil, errors.New("Unable to create struct binder: prototype is not a struct")
+	}
+
+	fields, err = resolveStructFields(structType, nil, "", nil, map[reflect.Type]bool{structType: true})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return func(p Parser, v interface{}) {
+
+		var structValue reflect.Value
+		var value interface{}
+
+		structValue = reflect.ValueOf(v)
+
+		if structValue.Kind() == reflect.Ptr && !structValue.IsNil() {
+			structValue = structValue.Elem()
+		}
+
+		if structValue.Type() != structType {
+			panic(fmt.Sprintf("npq: StructBinder: value of type %T does not match %s", v, structType))
+		}
+
+		for _, field := range fields {
+
+			if field.source >= 0 {
+				value = fieldSourceValue(structValue, field)
+			} else {
+				value = fieldValue(structValue, field.index)
+			}
+
+			if timeValue, isTime := value.(time.Time); isTime && len(field.timeFormat) > 0 {
+				value = timeValue.Format(field.timeFormat)
+			}
+
+			p.SetValue(field.name, value)
+		}
+	}, nil
+}
+
+// resolveStructFields appends every public field of the given [structType] to
+// [fields], in the same way as collectStructValues, but recursing into every
+// nested struct field, unless its type is already one of the given [visited]
+// types containing it. [index] is the path of indices of the struct within the
+// outermost struct, and [namePrefix] the prefix of its parameter names. An error
+// is returned if a sqlParameterSource tag names an invalid method.
+func resolveStructFields(structType reflect.Type, index []int, namePrefix string, fields []boundField, visited map[reflect.Type]bool) ([]boundField, error) {
+
+	var structField reflect.StructField
+	var fieldType reflect.Type
+	var fieldIndex []int
+	var queryTag string
+	var sourceTag string
+	var source int
+	var pointerSource bool
+	var visibilityCharacter rune
+	var err error
+
+	for i := 0; i < structType.NumField(); i++ {
+
+		structField = structType.Field(i)
+		queryTag = structField.Tag.Get(parameterNameTag)
+
+		if comma := strings.IndexByte(queryTag, ','); comma >= 0 {
+			queryTag = queryTag[:comma]
+		}
+
+		if queryTag == "-" {
+			continue
+		}
+
+		fieldIndex = make([]int, len(index)+1)
+		copy(fieldIndex, index)
+		fieldIndex[len(index)] = i
+
+		if structField.Anonymous && structField.Type.Kind() == reflect.Struct && len(queryTag) <= 0 {
+
+			fields, err = resolveStructFields(structField.Type, fieldIndex, namePrefix, fields, visited)
+
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		visibilityCharacter, _ = utf8.DecodeRuneInString(structField.Name)
+
+		if !unicode.IsUpper(visibilityCharacter) {
+			continue
+		}
+
+		if len(queryTag) <= 0 {
+			queryTag = structField.Name
+		}
+
+		queryTag = namePrefix + queryTag
+		source = -1
+		pointerSource = false
+
+		sourceTag = structField.Tag.Get(parameterSourceTag)
+
+		if len(sourceTag) > 0 {
+
+			source, pointerSource, err = resolveSourceMethod(structType, sourceTag, structField.Name)
+
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		fieldType = structField.Type
+
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+
+		// the fields of a value returned by a source method are not known in advance.
+		if fieldType.Kind() == reflect.Struct && !visited[fieldType] && source < 0 {
+
+			visited[fieldType] = true
+			fields, err = resolveStructFields(fieldType, fieldIndex, queryTag+".", fields, visited)
+			delete(visited, fieldType)
+
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		fields = append(fields, boundField{
+			index:         fieldIndex,
+			name:          queryTag,
+			timeFormat:    structField.Tag.Get("sqlTimeFormat"),
+			source:        source,
+			pointerSource: pointerSource,
+		})
+	}
+	return fields, nil
+}
+
+// resolveSourceMethod returns the index of the method with the given [methodName]
+// of the given [structType], or of a pointer to it, and whether it has a pointer
+// receiver, as named by the sqlParameterSource tag of the given [field]. An error
+// is returned in the same cases as by sourceValue.
+func resolveSourceMethod(structType reflect.Type, methodName string, field string) (int, bool, error) {
+
+	var method reflect.Method
+	var exists bool
+	var pointer bool
+
+	method, exists = structType.MethodByName(methodName)
+
+	if !exists {
+		method, exists = reflect.PtrTo(structType).MethodByName(methodName)
+		pointer = true
+	}
+
+	if !exists {
+		return -1, false, fmt.Errorf("Unable to create struct binder: field %s names method %s, which does not exist", field, methodName)
+	}
+
+	// the receiver is the first argument of a method taken from a type.
+	if method.Type.NumIn() != 1 || method.Type.NumOut() != 1 {
+		return -1, false, fmt.Errorf("Unable to create struct binder: method %s of field %s must take no arguments and return a single value", methodName, field)
+	}
+	return method.Index, pointer, nil
+}
+
+// fieldSourceValue returns the value returned by the source method of the given
+// [field] within the given [structValue], as bound by SetValuesFromStruct. If any
+// pointer to the struct containing the field is nil, nil is returned.
+func fieldSourceValue(structValue reflect.Value, field boundField) interface{} {
+
+	var copied reflect.Value
+
+	for _, i := range field.index[:len(field.index)-1] {
+
+		structValue = structValue.Field(i)
+
+		if structValue.Kind() == reflect.Ptr {
+
+			if structValue.IsNil() {
+				return nil
+			}
+			structValue = structValue.Elem()
+		}
+	}
+
+	if !field.pointerSource {
+		return derefValue(structValue.Method(field.source).Call(nil)[0])
+	}
+
+	// a struct value is not addressable, so its pointer method is called on a copy.
+	if !structValue.CanAddr() {
+
+		copied = reflect.New(structValue.Type()).Elem()
+		copied.Set(structValue)
+		structValue = copied
+	}
+	return derefValue(structValue.Addr().Method(field.source).Call(nil)[0])
+}
+
+// fieldValue returns the value of the field at the given [index] path within the
+// given [structValue], as bound by SetValuesFromStruct, following pointers to
+// nested structs. If any pointer along the path is nil, nil is returned.
+func fieldValue(structValue reflect.Value, index []int) interface{} {
+
+	for depth, i := range index {
+
+		structValue = structValue.Field(i)
+
+		if depth < len(index)-1 && structValue.Kind() == reflect.Ptr {
+
+			if structValue.IsNil() {
+				return nil
+			}
+			structValue = structValue.Elem()
+		}
+	}
+	return derefValue(structValue)
+}
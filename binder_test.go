@@ -0,0 +1,173 @@
+package npq
+
+import (
+	"testing"
+)
+
+type RecursiveParameterTest struct {
+	Name string
+	Next *RecursiveParameterTest
+}
+
+func TestStructBinder(test *testing.T) {
+
+	var prsr Parser
+	var bind func(p Parser, v interface{})
+	var nestedParam NestedParameterTest
+	var err error
+
+	bind, err = StructBinder(&NestedParameterTest{})
+
+	if err != nil {
+		test.Log("Expected a binder for a struct pointer. Actual: ", err)
+		test.FailNow()
+	}
+
+	nestedParam.User.Name = "alice"
+	nestedParam.User.Role = &Period{1, 2}
+	nestedParam.Manager = &NestedNameParameterTest{Name: "bob"}
+
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :user.name AND col2 = :Manager.name AND col3 = :user.Role.End AND col4 = :Absent.name AND col5 = :user.")
+	bind(prsr, nestedParam)
+
+	verifyStructParameters("StructBinder", test, prsr, []interface{}{
+		"alice",
+		"bob",
+		2,
+		nil,
+		nestedParam.User,
+	})
+
+	nestedParam.User.Name = "eve"
+	bind(prsr, &nestedParam)
+
+	verifyStructParameters("StructBinderPointer", test, prsr, []interface{}{
+		"eve",
+		"bob",
+		2,
+		nil,
+		nestedParam.User,
+	})
+}
+
+func TestStructBinderEmbedded(test *testing.T) {
+
+	var prsr Parser
+	var bind func(p Parser, v interface{})
+	var embeddedParam EmbeddedParameterTest
+
+	bind, _ = StructBinder(EmbeddedParameterTest{})
+
+	embeddedParam.CreatedBy = "alice"
+	embeddedParam.UpdatedBy = "bob"
+	embeddedParam.Version = 3
+	embeddedParam.Period = Period{1, 2}
+	embeddedParam.Name = "eve"
+	embeddedParam.Range = Period{3, 4}
+
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :CreatedBy AND col2 = :updater AND col3 = :Version AND col4 = :Name AND col5 = :Start AND col6 = :period AND col7 = :Range")
+	bind(prsr, embeddedParam)
+
+	verifyStructParameters("StructBinderEmbedded", test, prsr, []interface{}{
+		"alice",
+		"bob",
+		3,
+		"eve",
+		nil,
+		Period{1, 2},
+		Period{3, 4},
+	})
+}
+
+func TestStructBinderSource(test *testing.T) {
+
+	var prsr Parser
+	var bind func(p Parser, v interface{})
+	var sourceParam SourceParameterTest
+	var err error
+
+	bind, err = StructBinder(SourceParameterTest{})
+
+	if err != nil {
+		test.Log("Expected a binder for a struct with source methods. Actual: ", err)
+		test.FailNow()
+	}
+
+	sourceParam = SourceParameterTest{First: "Ada", Last: "Lovelace", FullName: "ignored"}
+
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :name AND col2 = :Initials AND col3 = :First")
+	bind(prsr, &sourceParam)
+
+	verifyStructParameters("StructBinderSource", test, prsr, []interface{}{
+		"Ada Lovelace",
+		"AL",
+		"Ada",
+	})
+
+	// a pointer receiver method is called on a copy of a struct value.
+	sourceParam.First = "Grace"
+	sourceParam.Last = "Hopper"
+	bind(prsr, sourceParam)
+
+	verifyStructParameters("StructBinderSourceValue", test, prsr, []interface{}{
+		"Grace Hopper",
+		"GH",
+		"Grace",
+	})
+
+	invalidParams := map[string]interface{}{
+		"Missing":   InvalidSourceParameterTest{},
+		"Arguments": ArgumentSourceParameterTest{},
+		"Results":   ResultsSourceParameterTest{},
+	}
+
+	for name, invalidParam := range invalidParams {
+
+		if _, err = StructBinder(invalidParam); err == nil {
+			test.Log("Test '", name, "': Expected an error for an invalid sqlParameterSource method")
+			test.Fail()
+		}
+	}
+}
+
+func TestStructBinderErrors(test *testing.T) {
+
+	var prsr Parser
+	var bind func(p Parser, v interface{})
+	var err error
+
+	if _, err = StructBinder(5); err == nil {
+		test.Log("Expected an error for a prototype which is not a struct")
+		test.Fail()
+	}
+
+	if _, err = StructBinder(nil); err == nil {
+		test.Log("Expected an error for a nil prototype")
+		test.Fail()
+	}
+
+	// a recursive type must not be resolved forever, so its nested fields are not bound.
+	bind, err = StructBinder(RecursiveParameterTest{})
+
+	if err != nil {
+		test.Log("Expected a binder for a recursive struct. Actual: ", err)
+		test.FailNow()
+	}
+
+	prsr = NewParser("SELECT :Name, :Next.Name")
+	bind(prsr, RecursiveParameterTest{Name: "foo", Next: &RecursiveParameterTest{Name: "bar"}})
+
+	verifyStructParameters("StructBinderRecursive", test, prsr, []interface{}{
+		"foo",
+		nil,
+	})
+
+	defer func() {
+		if recover() == nil {
+			test.Log("Expected a panic for a value of another type")
+			test.Fail()
+		}
+	}()
+
+	bind(prsr, Period{})
+}
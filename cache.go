@@ -0,0 +1,86 @@
+package npq
+
+import (
+	"container/list"
+	"sync"
+)
+
+// parseCacheSize is the number of distinct queries kept by ParseCached.
+const parseCacheSize = 512
+
+// parseCache holds the parsers created by ParseCached, with the most recently
+// used query at the front of its list.
+var parseCache = newQueryCache(parseCacheSize)
+
+// queryCache is a concurrency safe cache of parsed queries, keyed by their text,
+// which evicts the least recently used query once it holds more than its size.
+type queryCache struct {
+	mutex   sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+// queryCacheEntry is a single parsed query in a queryCache.
+type queryCacheEntry struct {
+	queryText string
+	parser    Parser
+}
+
+// newQueryCache creates an empty queryCache holding at most [size] queries.
+func newQueryCache(size int) *queryCache {
+
+	return &queryCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element, size),
+	}
+}
+
+// ParseCached returns a parser for the given [queryText] in the same way as
+// NewParser, but only parses every distinct query once, and returns a Clone of
+// that parser on every call, so that its values can be bound independently.
+// ParseCached is safe for concurrent use.
+//
+// The cache keeps the 512 most recently used queries, so its memory is bounded
+// even if queries are built dynamically, but such queries do not benefit from it.
+func ParseCached(queryText string) Parser {
+	return parseCache.get(queryText)
+}
+
+// get returns a Clone of the parser for the given [queryText], parsing it and
+// adding it to c if it is not cached yet.
+func (c *queryCache) get(queryText string) Parser {
+
+	var element *list.Element
+	var entry *queryCacheEntry
+	var exists bool
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, exists = c.entries[queryText]
+
+	if exists {
+		c.order.MoveToFront(element)
+		return element.Value.(*queryCacheEntry).parser.Clone()
+	}
+
+	entry = &queryCacheEntry{
+		queryText: queryText,
+		parser:    NewParser(queryText),
+	}
+
+	// render once, so that every clone shares the revised query.
+	entry.parser.GetParsedQuery()
+	c.entries[queryText] = c.order.PushFront(entry)
+
+	if c.order.Len() > c.size {
+
+		element = c.order.Back()
+		c.order.Remove(element)
+		delete(c.entries, element.Value.(*queryCacheEntry).queryText)
+	}
+
+	return entry.parser.Clone()
+}
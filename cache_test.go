@@ -0,0 +1,72 @@
+package npq
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestParseCached(test *testing.T) {
+
+	var group sync.WaitGroup
+	var first Parser
+	var second Parser
+
+	first = ParseCached("SELECT * FROM table WHERE col1 = :foo")
+	first.SetValue("foo", "foo")
+
+	second = ParseCached("SELECT * FROM table WHERE col1 = :foo")
+
+	if second.GetParsedQuery() != "SELECT * FROM table WHERE col1 = $1" || second.Validate() == nil {
+		test.Log("Expected a cached parser with its values unset. Actual: ", second.GetParsedParameters())
+		test.Fail()
+	}
+
+	verifyStructParameters("ParseCached", test, first, []interface{}{
+		"foo",
+	})
+
+	for i := 0; i < 8; i++ {
+
+		group.Add(1)
+
+		go func(value int) {
+
+			defer group.Done()
+
+			prsr := ParseCached("SELECT * FROM table WHERE col1 = :foo")
+			prsr.SetValue("foo", value)
+
+			if prsr.GetParsedParameters()[0] != value {
+				test.Log("Expected every cached parser to bind its own values")
+				test.Fail()
+			}
+		}(i)
+	}
+	group.Wait()
+}
+
+func TestQueryCacheEviction(test *testing.T) {
+
+	var cache *queryCache
+
+	cache = newQueryCache(2)
+	cache.get("SELECT :a")
+	cache.get("SELECT :b")
+	cache.get("SELECT :a")
+	cache.get("SELECT :c")
+
+	if _, exists := cache.entries["SELECT :b"]; exists || cache.order.Len() != 2 {
+		test.Log("Expected the least recently used query to be evicted. Actual: ", len(cache.entries))
+		test.Fail()
+	}
+
+	for i := 0; i < 10; i++ {
+		cache.get("SELECT :p" + strconv.Itoa(i))
+	}
+
+	if len(cache.entries) != 2 {
+		test.Log("Expected the cache to stay bounded. Actual: ", len(cache.entries))
+		test.Fail()
+	}
+}
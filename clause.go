@@ -0,0 +1,130 @@
+package npq
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+// optionalClause is a bracketed part of a query, e.g. "[AND status = :status]",
+// which is omitted from the revised query if none of its parameters are set.
+// Its text runs from byte [start] of the segment before occurrence [first] to
+// byte [end] of the segment before occurrence [last], so that it contains the
+// occurrences from first up to, but not including, last.
+type optionalClause struct {
+	first int
+	start int
+	last  int
+	end   int
+}
+
+// isSubscript returns true if a "[" following the text written to the given
+// [revisedBuilder] is an array subscript or a bracketed identifier of the query,
+// e.g. in "col[1]", rather than the start of an optional clause.
+func isSubscript(revisedBuilder *bytes.Buffer) bool {
+
+	var previous rune
+
+	previous, _ = utf8.DecodeLastRune(revisedBuilder.Bytes())
+
+	return isNameCharacter(previous) || previous == ']' || previous == ')' || previous == '"'
+}
+
+// omittedOccurrences returns, for every occurrence of a parameter in p query,
+// whether it is part of an optional clause which is omitted because none of its
+// parameters are set. A parameter with a default counts as set. If no clause is
+// omitted, nil is returned.
+func (p *parser) omittedOccurrences() []bool {
+
+	var omitted []bool
+	var set bool
+	var name string
+
+	for _, clause := range p.clauses {
+
+		set = clause.first >= clause.last
+
+		for occurrence := clause.first; occurrence < clause.last && !set; occurrence++ {
+
+			name = p.positionNames[p.occurrences[occurrence]]
+			_, set = p.defaults[name]
+			set = set || p.assigned[p.occurrences[occurrence]]
+		}
+
+		if set {
+			continue
+		}
+
+		if omitted == nil {
+			omitted = make([]bool, len(p.occurrences))
+		}
+
+		for occurrence := clause.first; occurrence < clause.last; occurrence++ {
+			omitted[occurrence] = true
+		}
+	}
+	return omitted
+}
+
+// omittedPositions returns, for every positional parameter of p query, whether
+// every occurrence referring to it is one of the given [occurrences] omitted, as
+// returned by omittedOccurrences, so that it has no placeholder in the revised
+// query. If no occurrence is omitted, nil is returned.
+func (p *parser) omittedPositions(occurrences []bool) []bool {
+
+	var omitted []bool
+
+	if occurrences == nil {
+		return nil
+	}
+
+	omitted = make([]bool, len(p.parameters))
+
+	for position := range omitted {
+		omitted[position] = true
+	}
+
+	for occurrence, position := range p.occurrences {
+		if !occurrences[occurrence] {
+			omitted[position] = false
+		}
+	}
+	return omitted
+}
+
+// writeSegment writes the given [segment], which precedes the given [occurrence],
+// to [revisedBuilder], without the text of any clause which is omitted, as
+// reported by the given [omitted] occurrences.
+func (p *parser) writeSegment(revisedBuilder *bytes.Buffer, segment string, occurrence int, omitted []bool) {
+
+	var cursor int
+	var start int
+	var end int
+
+	for _, clause := range p.clauses {
+
+		if omitted == nil || clause.first > occurrence || clause.last < occurrence {
+			continue
+		}
+
+		// clauses without parameters are never omitted.
+		if clause.first >= clause.last || !omitted[clause.first] {
+			continue
+		}
+
+		start = 0
+		end = len(segment)
+
+		if clause.first == occurrence {
+			start = clause.start
+		}
+
+		if clause.last == occurrence {
+			end = clause.end
+		}
+
+		revisedBuilder.WriteString(segment[cursor:start])
+		cursor = end
+	}
+
+	revisedBuilder.WriteString(segment[cursor:])
+}
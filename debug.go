@@ -0,0 +1,73 @@
+package npq
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DebugQuery returns the revised query of p, as returned by GetParsedQuery, with
+// every placeholder replaced by the value bound to it written as a literal, e.g.
+// "SELECT * FROM table WHERE col1 = 'foo' AND col2 = 42", so that a query can be
+// reproduced from a log.
+//
+// Strings, byte slices and times are quoted with single quotes, doubling any quote
+// inside them; numbers are written as is, booleans as TRUE or FALSE, and nil values
+// as NULL. Any other value is quoted as formatted by fmt.
+//
+// The quoting is only meant to be readable, and does not escape values safely for
+// any database. The returned query must never be executed; use GetParsedQuery and
+// GetParsedParameters instead.
+func (p *parser) DebugQuery() string {
+
+	return p.renderWith(func(position int, index int, value interface{}) string {
+		return debugLiteral(value)
+	})
+}
+
+// debugLiteral returns the given [value] written as a SQL literal, in the way
+// described by DebugQuery.
+func debugLiteral(value interface{}) string {
+
+	var reflected reflect.Value
+
+	switch typed := value.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return quoteLiteral(typed)
+	case []byte:
+		return quoteLiteral(string(typed))
+	case time.Time:
+		return quoteLiteral(typed.Format(time.RFC3339Nano))
+	}
+
+	reflected = reflect.ValueOf(value)
+
+	switch reflected.Kind() {
+	case reflect.Ptr:
+		return debugLiteral(derefValue(reflected))
+	case reflect.Bool:
+		if reflected.Bool() {
+			return "TRUE"
+		}
+		return "FALSE"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(reflected.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(reflected.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(reflected.Float(), 'g', -1, reflected.Type().Bits())
+	case reflect.String:
+		return quoteLiteral(reflected.String())
+	}
+	return quoteLiteral(fmt.Sprint(value))
+}
+
+// quoteLiteral returns the given [text] enclosed in single quotes, with every
+// single quote inside it doubled.
+func quoteLiteral(text string) string {
+	return "'" + strings.Replace(text, "'", "''", -1) + "'"
+}
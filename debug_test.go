@@ -0,0 +1,46 @@
+package npq
+
+import (
+	"testing"
+	"time"
+)
+
+type DebugStatus int
+
+func TestDebugQuery(test *testing.T) {
+
+	var prsr Parser
+	var missing *int
+
+	moment := time.Date(2016, time.March, 14, 15, 9, 26, 0, time.UTC)
+
+	prsr = NewParser("SELECT * FROM table WHERE a = :a AND b = :b AND c = :c AND d = :d AND e = :e AND f = :f AND g = :g AND h IN (:h) AND i = :i -- :a")
+	prsr.SetValue("a", "it's")
+	prsr.SetValue("b", 42)
+	prsr.SetValue("c", 1.5)
+	prsr.SetValue("d", true)
+	prsr.SetValue("e", nil)
+	prsr.SetValue("f", moment)
+	prsr.SetValue("g", DebugStatus(3))
+	prsr.SetValue("h", []string{"x", "y"})
+	prsr.SetValue("i", missing)
+
+	if prsr.DebugQuery() != "SELECT * FROM table WHERE a = 'it''s' AND b = 42 AND c = 1.5 AND d = TRUE AND e = NULL AND f = '2016-03-14T15:09:26Z' AND g = 3 AND h IN ('x', 'y') AND i = NULL -- :a" {
+		test.Log("Unexpected debug query: ", prsr.DebugQuery())
+		test.Fail()
+	}
+
+	// the revised query itself must not be affected.
+	if prsr.GetParsedQuery() != "SELECT * FROM table WHERE a = $1 AND b = $2 AND c = $3 AND d = $4 AND e = $5 AND f = $6 AND g = $7 AND h IN ($8, $9) AND i = $10 -- :a" {
+		test.Log("Expected the parsed query to keep its placeholders. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+
+	// unset parameters are written as NULL, as they would be bound.
+	prsr = NewParserForDriver("SELECT :foo, [:bar]", DriverMySQL)
+
+	if prsr.DebugQuery() != "SELECT NULL, [NULL]" {
+		test.Log("Expected unset parameters to be written as NULL. Actual: ", prsr.DebugQuery())
+		test.Fail()
+	}
+}
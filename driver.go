@@ -0,0 +1,123 @@
+package npq
+
+import (
+	"strconv"
+)
+
+// Dialect identifies the SQL dialect of a query, and so the positional
+// placeholder syntax that a parser emits when it rewrites named parameters.
+type Dialect int
+
+const (
+	// DialectPostgres emits numbered placeholders such as "$1", "$2".
+	DialectPostgres Dialect = iota
+
+	// DialectMySQL emits anonymous "?" placeholders.
+	DialectMySQL
+
+	// DialectSQLServer emits numbered placeholders such as "@p1", "@p2", as
+	// expected by go-mssqldb.
+	DialectSQLServer
+
+	// DialectOracle emits numbered bind variables such as ":1", ":2". These
+	// are only emitted, never parsed: a ":1" in the original query is still
+	// the named parameter "1", and is renumbered by its position like any
+	// other named parameter.
+	DialectOracle
+
+	// DialectNamed keeps the named parameters of the original query, e.g.
+	// ":name" or "@name", for drivers which bind parameters by name, such as
+	// pgx; their values are returned by GetNamedArgs. Slice values are never
+	// expanded, and anonymous positional parameters are emitted as "?". The
+	// query is read as a Postgres query, so dollar quoted strings are skipped.
+	// A name in braces is written without them, so it must not be directly
+	// followed by characters which could be part of it, e.g. ":{id}s".
+	DialectNamed
+)
+
+// Driver is the former name of Dialect, kept so that existing code compiles.
+type Driver = Dialect
+
+// The former names of the dialects, kept so that existing code compiles.
+const (
+	DriverPostgres  = DialectPostgres
+	DriverMySQL     = DialectMySQL
+	DriverSQLServer = DialectSQLServer
+	DriverOracle    = DialectOracle
+)
+
+// dialectCapabilities describes how the queries of a dialect are written.
+type dialectCapabilities struct {
+
+	// The prefix of a numbered placeholder, e.g. "$" for "$1", or the whole
+	// placeholder if it is not numbered.
+	placeholderPrefix string
+
+	// Whether placeholders are followed by their 1-based index.
+	numbered bool
+
+	// Whether a placeholder may occur more than once, e.g. "$1 AND $1", so that
+	// a repeated parameter takes a single value. Anonymous placeholders such as
+	// "?", and Oracle bind variables, which are bound by position, take one
+	// value per occurrence instead.
+	reusable bool
+
+	// Whether string literals may contain backslash escaped characters, such as \' in MySQL.
+	backslashEscapes bool
+
+	// Whether strings may be dollar quoted, e.g. "$$ ... $$" in Postgres.
+	dollarQuotes bool
+
+	// Whether parameters keep their names instead of being replaced by placeholders.
+	named bool
+}
+
+// dialects holds the capabilities of every dialect, indexed by the dialect.
+var dialects = [...]dialectCapabilities{
+	DialectPostgres:  {placeholderPrefix: "$", numbered: true, reusable: true, dollarQuotes: true},
+	DialectMySQL:     {placeholderPrefix: "?", backslashEscapes: true},
+	DialectSQLServer: {placeholderPrefix: "@p", numbered: true, reusable: true},
+	DialectOracle:    {placeholderPrefix: ":", numbered: true},
+	DialectNamed:     {placeholderPrefix: "?", reusable: true, dollarQuotes: true, named: true},
+}
+
+// capabilities returns the capabilities of the dialect, or those of
+// DialectPostgres if it is not a known dialect.
+func (d Dialect) capabilities() dialectCapabilities {
+
+	if d < 0 || int(d) >= len(dialects) {
+		return dialects[DialectPostgres]
+	}
+	return dialects[d]
+}
+
+// placeholder returns the positional placeholder text for the given 1-based
+// [index] in the syntax of the dialect.
+func (d Dialect) placeholder(index int) string {
+
+	var capabilities dialectCapabilities
+
+	capabilities = d.capabilities()
+
+	if !capabilities.numbered {
+		return capabilities.placeholderPrefix
+	}
+	return capabilities.placeholderPrefix + strconv.Itoa(index)
+}
+
+// reusesPlaceholders returns true if a placeholder of the dialect may occur more
+// than once in a query, so that repeated parameters can be deduplicated.
+func (d Dialect) reusesPlaceholders() bool {
+	return d.capabilities().reusable
+}
+
+// backslashEscapes returns true if the dialect's string literals may contain
+// backslash escaped characters, such as \' in MySQL.
+func (d Dialect) backslashEscapes() bool {
+	return d.capabilities().backslashEscapes
+}
+
+// dollarQuotes returns true if the dialect's strings may be dollar quoted.
+func (d Dialect) dollarQuotes() bool {
+	return d.capabilities().dollarQuotes
+}
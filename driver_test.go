@@ -0,0 +1,234 @@
+package npq
+
+import (
+	"database/sql"
+	"testing"
+)
+
+// DriverPlaceholderTest represents a single test of placeholder generation.
+// Given an [Input] query parsed for [Driver], if the revised query does not
+// match the [Expected] string, the test fails.
+type DriverPlaceholderTest struct {
+	Name     string
+	Driver   Driver
+	Input    string
+	Expected string
+}
+
+func TestDriverPlaceholders(test *testing.T) {
+
+	var prsr Parser
+
+	DriverPlaceholderTests := []DriverPlaceholderTest{
+		DriverPlaceholderTest{
+			Name:     "Postgres",
+			Driver:   DriverPostgres,
+			Input:    "SELECT * FROM table WHERE col1 = :foo AND col2 = :bar AND col3 = :foo",
+			Expected: "SELECT * FROM table WHERE col1 = $1 AND col2 = $2 AND col3 = $3",
+		},
+		DriverPlaceholderTest{
+			Name:     "MySQL",
+			Driver:   DriverMySQL,
+			Input:    "SELECT * FROM table WHERE col1 = :foo AND col2 = :bar AND col3 = :foo",
+			Expected: "SELECT * FROM table WHERE col1 = ? AND col2 = ? AND col3 = ?",
+		},
+		DriverPlaceholderTest{
+			Name:     "SQLServer",
+			Driver:   DriverSQLServer,
+			Input:    "SELECT * FROM table WHERE col1 = :foo AND col2 = :bar AND col3 = :foo",
+			Expected: "SELECT * FROM table WHERE col1 = @p1 AND col2 = @p2 AND col3 = @p3",
+		},
+		DriverPlaceholderTest{
+			Name:     "Oracle",
+			Driver:   DriverOracle,
+			Input:    "SELECT * FROM table WHERE col1 = :foo AND col2 = :bar AND col3 = :foo",
+			Expected: "SELECT * FROM table WHERE col1 = :1 AND col2 = :2 AND col3 = :3",
+		},
+	}
+
+	for _, placeholderTest := range DriverPlaceholderTests {
+
+		prsr = NewParserForDriver(placeholderTest.Input, placeholderTest.Driver)
+
+		if prsr.GetParsedQuery() != placeholderTest.Expected {
+			test.Log("Test '", placeholderTest.Name, "': Expected placeholders did not match actual parsed output")
+			test.Log("Actual: ", prsr.GetParsedQuery())
+			test.Fail()
+		}
+	}
+
+	// the default parser must keep emitting Postgres placeholders.
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :foo")
+
+	if prsr.GetParsedQuery() != "SELECT * FROM table WHERE col1 = $1" {
+		test.Log("Test 'Default': Expected Postgres placeholders from NewParser. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+}
+
+// The positional parameters must be identical regardless of the target driver.
+func TestDriverParameterOrdering(test *testing.T) {
+
+	var prsr Parser
+	var parameters []interface{}
+
+	drivers := []Driver{DriverPostgres, DriverMySQL, DriverSQLServer, DriverOracle}
+	expected := []interface{}{"something", "else", "something"}
+
+	for _, driver := range drivers {
+
+		prsr = NewParserForDriver("SELECT * FROM table WHERE col1 = :foo AND col2 = :bar AND col3 = :foo", driver)
+		prsr.SetValue("foo", "something")
+		prsr.SetValue("bar", "else")
+
+		parameters = prsr.GetParsedParameters()
+
+		if len(parameters) != len(expected) {
+			test.Log("Driver ", driver, ": Expected ", len(expected), " parameters, got ", len(parameters))
+			test.Fail()
+			continue
+		}
+
+		for index, parameter := range parameters {
+			if parameter != expected[index] {
+				test.Log("Driver ", driver, ": Parameter at position ", index, " (", parameter, ") did not match expected parameter (", expected[index], ")")
+				test.Fail()
+			}
+		}
+	}
+}
+
+// Backslash escaped quotes only exist in MySQL string literals; Postgres keeps
+// standard conforming strings, where a backslash is an ordinary character.
+func TestDriverBackslashEscapes(test *testing.T) {
+
+	DriverPlaceholderTests := []DriverPlaceholderTest{
+		DriverPlaceholderTest{
+			Name:     "MySQLEscapedQuote",
+			Driver:   DriverMySQL,
+			Input:    `SELECT * FROM table WHERE col1 = 'a\'b :literal' AND col2 = :foo`,
+			Expected: `SELECT * FROM table WHERE col1 = 'a\'b :literal' AND col2 = ?`,
+		},
+		DriverPlaceholderTest{
+			Name:     "MySQLEscapedBackslash",
+			Driver:   DriverMySQL,
+			Input:    `SELECT * FROM table WHERE col1 = 'a\\' AND col2 = :foo`,
+			Expected: `SELECT * FROM table WHERE col1 = 'a\\' AND col2 = ?`,
+		},
+		DriverPlaceholderTest{
+			Name:     "PostgresBackslash",
+			Driver:   DriverPostgres,
+			Input:    `SELECT * FROM table WHERE col1 = 'a\' AND col2 = :foo`,
+			Expected: `SELECT * FROM table WHERE col1 = 'a\' AND col2 = $1`,
+		},
+	}
+
+	for _, placeholderTest := range DriverPlaceholderTests {
+
+		prsr := NewParserForDriver(placeholderTest.Input, placeholderTest.Driver)
+
+		if prsr.GetParsedQuery() != placeholderTest.Expected {
+			test.Log("Test '", placeholderTest.Name, "': Expected escaped literal did not match actual parsed output")
+			test.Log("Actual: ", prsr.GetParsedQuery())
+			test.Fail()
+		}
+	}
+}
+
+func TestGetDialect(test *testing.T) {
+
+	var prsr Parser
+
+	if NewParser("SELECT :foo").GetDialect() != DialectPostgres {
+		test.Log("Expected the default dialect to be Postgres")
+		test.Fail()
+	}
+
+	prsr = NewParserForDriver("SELECT :foo", DriverMySQL)
+
+	if prsr.GetDialect() != DialectMySQL || prsr.Clone().GetDialect() != DialectMySQL {
+		test.Log("Expected the dialect given to the parser. Actual: ", prsr.GetDialect())
+		test.Fail()
+	}
+
+	prsr = NewParserWithOptions("SELECT :foo", WithDriver(DialectSQLServer))
+
+	if prsr.GetDialect() != DialectSQLServer {
+		test.Log("Expected the dialect given as an option. Actual: ", prsr.GetDialect())
+		test.Fail()
+	}
+}
+
+// Deduplication must only apply to dialects whose placeholders may be reused.
+func TestDialectDeduplication(test *testing.T) {
+
+	var prsr Parser
+
+	DeduplicationTests := []struct {
+		DriverPlaceholderTest
+		Parameters int
+	}{
+		{DriverPlaceholderTest{Name: "Postgres", Driver: DialectPostgres, Expected: "SELECT * FROM table WHERE col1 = $1 AND col2 = $2 AND col3 = $1"}, 2},
+		{DriverPlaceholderTest{Name: "MySQL", Driver: DialectMySQL, Expected: "SELECT * FROM table WHERE col1 = ? AND col2 = ? AND col3 = ?"}, 3},
+		{DriverPlaceholderTest{Name: "SQLServer", Driver: DialectSQLServer, Expected: "SELECT * FROM table WHERE col1 = @p1 AND col2 = @p2 AND col3 = @p1"}, 2},
+		{DriverPlaceholderTest{Name: "Oracle", Driver: DialectOracle, Expected: "SELECT * FROM table WHERE col1 = :1 AND col2 = :2 AND col3 = :3"}, 3},
+	}
+
+	for _, deduplicationTest := range DeduplicationTests {
+
+		prsr = NewParserWithOptions("SELECT * FROM table WHERE col1 = :foo AND col2 = :bar AND col3 = :foo", WithDriver(deduplicationTest.Driver), WithDeduplication())
+
+		if prsr.GetParsedQuery() != deduplicationTest.Expected {
+			test.Log("Test '", deduplicationTest.Name, "': Unexpected deduplicated query: ", prsr.GetParsedQuery())
+			test.Fail()
+		}
+
+		// every placeholder bound by position must have its own value.
+		if len(prsr.GetParsedParameters()) != deduplicationTest.Parameters || prsr.ParameterCount("foo") != 2 {
+			test.Log("Test '", deduplicationTest.Name, "': Unexpected positional parameters: ", prsr.GetParsedParameters())
+			test.Fail()
+		}
+	}
+}
+
+func TestNamedDialect(test *testing.T) {
+
+	var prsr Parser
+	var namedArgs []sql.NamedArg
+	var err error
+
+	prsr = NewParserWithOptions("SELECT * FROM table WHERE col1 = @foo AND col2 IN (@ids) AND col3 = @foo AND col4 = '@literal' [AND col5 = @bar]", WithDriver(DialectNamed), WithPrefix('@'), WithOptionalClauses())
+	prsr.SetValue("foo", "foo")
+	prsr.SetValue("ids", []int{1, 2})
+
+	if prsr.GetParsedQuery() != "SELECT * FROM table WHERE col1 = @foo AND col2 IN (@ids) AND col3 = @foo AND col4 = '@literal' " {
+		test.Log("Expected the names of the original query to be kept. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+
+	namedArgs = prsr.GetNamedArgs()
+
+	if len(namedArgs) != 2 || namedArgs[0].Name != "foo" || namedArgs[0].Value != "foo" || namedArgs[1].Name != "ids" || len(namedArgs[1].Value.([]int)) != 2 {
+		test.Log("Expected a named argument for every bound parameter. Actual: ", namedArgs)
+		test.Fail()
+	}
+
+	// dollar quoted strings are skipped, as in the Postgres dialect.
+	prsr, err = NewParserStrictWithOptions("SELECT $$ it's :x $$, :a", WithDriver(DialectNamed))
+
+	if err != nil || prsr.GetParsedQuery() != "SELECT $$ it's :x $$, :a" || len(prsr.ParameterNames()) != 1 || prsr.ParameterNames()[0] != "a" {
+		test.Log("Expected a dollar quoted body to be skipped. Actual: ", err, prsr)
+		test.Fail()
+	}
+
+	// named arguments are also available for positional dialects.
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :foo AND col2 = :bar")
+	prsr.SetValue("bar", 2)
+
+	namedArgs = prsr.GetNamedArgs()
+
+	if len(namedArgs) != 2 || namedArgs[0].Name != "foo" || namedArgs[0].Value != nil || namedArgs[1].Value != 2 {
+		test.Log("Expected a named argument for every parameter. Actual: ", namedArgs)
+		test.Fail()
+	}
+}
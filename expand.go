@@ -0,0 +1,241 @@
+package npq
+
+import (
+	"bytes"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// expandValue returns the elements of the given [value] if it is a slice or
+// array which should be bound as one positional parameter per element, or nil
+// otherwise. Byte slices are not expanded, since drivers treat them as a
+// single binary value.
+func expandValue(value interface{}) []interface{} {
+
+	var reflectValue reflect.Value
+	var expansion []interface{}
+
+	if value == nil {
+		return nil
+	}
+
+	reflectValue = reflect.ValueOf(value)
+
+	if reflectValue.Kind() != reflect.Slice && reflectValue.Kind() != reflect.Array {
+		return nil
+	}
+
+	if reflectValue.Type().Elem().Kind() == reflect.Uint8 {
+		return nil
+	}
+
+	// an empty list still needs a placeholder to remain valid SQL.
+	if reflectValue.Len() == 0 {
+		return []interface{}{nil}
+	}
+
+	expansion = make([]interface{}, reflectValue.Len())
+
+	for i := 0; i < reflectValue.Len(); i++ {
+		expansion[i] = reflectValue.Index(i).Interface()
+	}
+	return expansion
+}
+
+// misplacedExpansions returns the names of the parameters of p query which are
+// bound to a value expanding into multiple placeholders, but which occur outside
+// of a parenthesized list, e.g. in "col = :ids" instead of "col IN (:ids)", where
+// the expanded placeholders would produce invalid SQL. An occurrence is in a list
+// if the nearest text around it, ignoring whitespace, is an opening parenthesis
+// or comma before it, and a closing parenthesis or comma after it. The given
+// [omitted] occurrences, as returned by omittedOccurrences, are not checked.
+func (p *parser) misplacedExpansions(omitted []bool) []string {
+
+	var misplaced []string
+	var reported map[string]bool
+	var before string
+	var after string
+
+	for occurrence, position := range p.occurrences {
+
+		if p.expansions[position] == nil || reported[p.positionNames[position]] || (omitted != nil && omitted[occurrence]) {
+			continue
+		}
+
+		before = strings.TrimRightFunc(p.segments[occurrence], unicode.IsSpace)
+		after = strings.TrimLeftFunc(p.segments[occurrence+1], unicode.IsSpace)
+
+		if (strings.HasSuffix(before, "(") || strings.HasSuffix(before, ",")) && (strings.HasPrefix(after, ")") || strings.HasPrefix(after, ",")) {
+			continue
+		}
+
+		if reported == nil {
+			reported = make(map[string]bool)
+		}
+
+		// anonymous parameters are reported by their position instead.
+		if len(p.positionNames[position]) <= 0 {
+			misplaced = append(misplaced, "?"+strconv.Itoa(position+1))
+			continue
+		}
+
+		reported[p.positionNames[position]] = true
+		misplaced = append(misplaced, string(p.prefix)+p.positionNames[position])
+	}
+	return misplaced
+}
+
+// expansion returns the elements of the given [value] which must be bound as one
+// positional parameter each, as returned by expandValue and transformed in the
+// same way as a single value, or nil if the placeholders of p query cannot be
+// expanded, because they keep their names, or because they have already been
+// written by ParseStream.
+func (p *parser) expansion(value interface{}) []interface{} {
+
+	var expansion []interface{}
+
+	if p.streamed || p.dialect.capabilities().named {
+		return nil
+	}
+
+	expansion = expandValue(value)
+
+	for i := range expansion {
+		expansion[i] = p.transform(expansion[i])
+	}
+	return expansion
+}
+
+// isRewritten returns true if the revised query currently differs from the one
+// rendered for the parsed query alone, i.e. if any positional parameter is bound
+// to a value which expands into multiple placeholders, or an optional clause is
+// omitted.
+func (p *parser) isRewritten() bool {
+
+	for _, expansion := range p.expansions {
+		if expansion != nil {
+			return true
+		}
+	}
+	return p.omittedOccurrences() != nil
+}
+
+// placeholderStarts returns, for every positional parameter, the 1-based number
+// of its first placeholder in the revised query. Parameters bound to an expanded
+// value take one placeholder number per element, and the given [omitted]
+// positions take none. Numbering continues after the placeholder offset of p,
+// as given to NewParserWithOffset, and after any existing numbered placeholder
+// kept by WithDollarNames.
+func (p *parser) placeholderStarts(omitted []bool) []int {
+
+	var starts []int
+	var index int
+	var statement int
+
+	starts = make([]int, len(p.parameters))
+	index = p.placeholderOffset + p.existingPlaceholders
+
+	for position := range p.parameters {
+
+		// numbering starts again at the first positional parameter of every statement.
+		for statement < len(p.statements) && p.statements[statement] <= position {
+			index = 0
+			statement++
+		}
+
+		starts[position] = index + 1
+
+		if omitted != nil && omitted[position] {
+			continue
+		}
+
+		if p.expansions[position] == nil {
+			index++
+		} else {
+			index += len(p.expansions[position])
+		}
+	}
+	return starts
+}
+
+// placeholder returns the positional placeholder text for the given 1-based
+// [index], as produced by the placeholder format of p if it has one, or in the
+// syntax of its driver otherwise.
+func (p *parser) placeholder(index int) string {
+
+	if p.placeholderFormat != nil {
+		return p.placeholderFormat(index)
+	}
+	return p.dialect.placeholder(index)
+}
+
+// render builds the revised query from the parsed segments. Every positional
+// parameter is given the next unused placeholder indices, one for each element
+// of an expanded value, and each occurrence is rendered with the placeholders
+// of the positional parameter it refers to. Omitted optional clauses are left out.
+func (p *parser) render() string {
+
+	var named bool
+
+	named = p.dialect.capabilities().named
+
+	return p.renderWith(func(position int, index int, value interface{}) string {
+
+		// a named dialect keeps the names of the original query.
+		if !named {
+			return p.placeholder(index)
+		}
+
+		if len(p.positionNames[position]) <= 0 {
+			return "?"
+		}
+		return string(p.prefix) + p.positionNames[position]
+	})
+}
+
+// renderWith builds the query from the parsed segments in the same way as render,
+// but writes the text returned by the given [write] function for every placeholder,
+// given the positional parameter it belongs to, its 1-based index and the value
+// bound to it.
+func (p *parser) renderWith(write func(position int, index int, value interface{}) string) string {
+
+	var revisedBuilder bytes.Buffer
+	var starts []int
+	var omitted []bool
+	var position int
+
+	omitted = p.omittedOccurrences()
+	starts = p.placeholderStarts(p.omittedPositions(omitted))
+
+	for occurrence, segment := range p.segments {
+
+		p.writeSegment(&revisedBuilder, segment, occurrence, omitted)
+
+		if occurrence >= len(p.occurrences) {
+			break
+		}
+
+		if omitted != nil && omitted[occurrence] {
+			continue
+		}
+
+		position = p.occurrences[occurrence]
+
+		if p.expansions[position] == nil {
+			revisedBuilder.WriteString(write(position, starts[position], p.parameters[position]))
+			continue
+		}
+
+		for j := range p.expansions[position] {
+
+			if j > 0 {
+				revisedBuilder.WriteString(", ")
+			}
+
+			revisedBuilder.WriteString(write(position, starts[position]+j, p.expansions[position][j]))
+		}
+	}
+	return revisedBuilder.String()
+}
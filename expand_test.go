@@ -0,0 +1,256 @@
+package npq
+
+import (
+	"testing"
+)
+
+// ExpansionTest represents a single test of slice expansion. Given a [Query]
+// and a set of [Parameters], the revised query must match [Expected] and the
+// positional parameters must match [ExpectedParameters].
+type ExpansionTest struct {
+	Name               string
+	Driver             Driver
+	Query              string
+	Parameters         []TestQueryParameter
+	Expected           string
+	ExpectedParameters []interface{}
+}
+
+func TestSliceExpansion(test *testing.T) {
+
+	var prsr Parser
+	var actualParameters []interface{}
+
+	ExpansionTests := []ExpansionTest{
+		ExpansionTest{
+			Name:  "IntSlice",
+			Query: "SELECT * FROM table WHERE id IN (:ids)",
+			Parameters: []TestQueryParameter{
+				TestQueryParameter{
+					Name:  "ids",
+					Value: []int{1, 2, 3},
+				},
+			},
+			Expected:           "SELECT * FROM table WHERE id IN ($1, $2, $3)",
+			ExpectedParameters: []interface{}{1, 2, 3},
+		},
+		ExpansionTest{
+			Name:  "RenumbersFollowingParameters",
+			Query: "SELECT * FROM table WHERE col1 = :foo AND id IN (:ids) AND col2 = :bar",
+			Parameters: []TestQueryParameter{
+				TestQueryParameter{
+					Name:  "foo",
+					Value: "foo",
+				},
+				TestQueryParameter{
+					Name:  "ids",
+					Value: []string{"a", "b"},
+				},
+				TestQueryParameter{
+					Name:  "bar",
+					Value: "bar",
+				},
+			},
+			Expected:           "SELECT * FROM table WHERE col1 = $1 AND id IN ($2, $3) AND col2 = $4",
+			ExpectedParameters: []interface{}{"foo", "a", "b", "bar"},
+		},
+		ExpansionTest{
+			Name:   "MySQL",
+			Driver: DriverMySQL,
+			Query:  "SELECT * FROM table WHERE id IN (:ids) OR parent IN (:ids)",
+			Parameters: []TestQueryParameter{
+				TestQueryParameter{
+					Name:  "ids",
+					Value: [2]int{4, 5},
+				},
+			},
+			Expected:           "SELECT * FROM table WHERE id IN (?, ?) OR parent IN (?, ?)",
+			ExpectedParameters: []interface{}{4, 5, 4, 5},
+		},
+		ExpansionTest{
+			Name:  "EmptySlice",
+			Query: "SELECT * FROM table WHERE id IN (:ids)",
+			Parameters: []TestQueryParameter{
+				TestQueryParameter{
+					Name:  "ids",
+					Value: []int{},
+				},
+			},
+			Expected:           "SELECT * FROM table WHERE id IN ($1)",
+			ExpectedParameters: []interface{}{nil},
+		},
+		ExpansionTest{
+			Name:  "ByteSliceNotExpanded",
+			Query: "SELECT * FROM table WHERE data = :data",
+			Parameters: []TestQueryParameter{
+				TestQueryParameter{
+					Name:  "data",
+					Value: []byte("abc"),
+				},
+			},
+			Expected:           "SELECT * FROM table WHERE data = $1",
+			ExpectedParameters: nil,
+		},
+	}
+
+	for _, expansionTest := range ExpansionTests {
+
+		prsr = NewParserForDriver(expansionTest.Query, expansionTest.Driver)
+
+		for _, queryVariable := range expansionTest.Parameters {
+			prsr.SetValue(queryVariable.Name, queryVariable.Value)
+		}
+
+		if prsr.GetParsedQuery() != expansionTest.Expected {
+			test.Log("Test '", expansionTest.Name, "': Expected expanded query did not match actual parsed output")
+			test.Log("Actual: ", prsr.GetParsedQuery())
+			test.Fail()
+		}
+
+		if expansionTest.ExpectedParameters == nil {
+			continue
+		}
+
+		actualParameters = prsr.GetParsedParameters()
+
+		if len(actualParameters) != len(expansionTest.ExpectedParameters) {
+			test.Log("Test '", expansionTest.Name, "': Expected ", len(expansionTest.ExpectedParameters), " parameters, got ", len(actualParameters))
+			test.Fail()
+			continue
+		}
+
+		for index, parameter := range actualParameters {
+			if parameter != expansionTest.ExpectedParameters[index] {
+				test.Log("Test '", expansionTest.Name, "': Actual parameter at position ", index, " (", parameter, ") did not match expected parameter (", expansionTest.ExpectedParameters[index], ")")
+				test.Fail()
+			}
+		}
+	}
+}
+
+// Rebinding a scalar value must undo a previous expansion.
+func TestSliceExpansionRebind(test *testing.T) {
+
+	var prsr Parser
+
+	prsr = NewParser("SELECT * FROM table WHERE id IN (:ids)")
+	prsr.SetValue("ids", []int{1, 2, 3})
+	prsr.SetValue("ids", 7)
+
+	if prsr.GetParsedQuery() != "SELECT * FROM table WHERE id IN ($1)" {
+		test.Log("Expected expansion to be undone. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+
+	if len(prsr.GetParsedParameters()) != 1 || prsr.GetParsedParameters()[0] != 7 {
+		test.Log("Expected a single rebound parameter. Actual: ", prsr.GetParsedParameters())
+		test.Fail()
+	}
+}
+
+// Placeholders must only be rendered once the query is requested, and then only once
+// for as long as no parameter is expanded.
+func TestLazyRendering(test *testing.T) {
+
+	var prsr Parser
+	var calls int
+
+	format := func(index int) string {
+		calls++
+		return "?"
+	}
+
+	prsr = NewParserWithOptions("SELECT * FROM table WHERE col1 = :foo AND col2 = :bar", WithPlaceholderFormat(format))
+
+	if calls != 0 {
+		test.Log("Expected no placeholders to be rendered when parsing. Actual: ", calls)
+		test.Fail()
+	}
+
+	prsr.GetParsedQuery()
+	prsr.GetParsedQuery()
+
+	if calls != 2 {
+		test.Log("Expected the placeholders to be rendered once. Actual: ", calls)
+		test.Fail()
+	}
+
+	prsr.Reparse("SELECT :baz")
+
+	if prsr.GetParsedQuery() != "SELECT ?" || calls != 3 {
+		test.Log("Expected the reparsed query to be rendered again. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+}
+
+func TestSetArrayValue(test *testing.T) {
+
+	var prsr Parser
+	var ids []int
+
+	ids = []int{1, 2, 3}
+
+	prsr = NewParser("SELECT * FROM table WHERE id = ANY(:ids) AND parent IN (:parents)")
+	prsr.SetArrayValue("ids", ids)
+	prsr.SetValue("parents", ids)
+
+	if prsr.GetParsedQuery() != "SELECT * FROM table WHERE id = ANY($1) AND parent IN ($2, $3, $4)" {
+		test.Log("Expected an array value to keep a single placeholder. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+
+	if len(prsr.GetParsedParameters()) != 4 {
+		test.Log("Expected the array value to be a single parameter. Actual: ", prsr.GetParsedParameters())
+		test.FailNow()
+	}
+
+	if array, isSlice := prsr.GetParsedParameters()[0].([]int); !isSlice || len(array) != 3 {
+		test.Log("Expected the array value to be passed through as is. Actual: ", prsr.GetParsedParameters()[0])
+		test.Fail()
+	}
+}
+
+func TestMisplacedExpansion(test *testing.T) {
+
+	var prsr Parser
+	var err error
+
+	prsr = NewParser("SELECT * FROM table WHERE col1 IN ( :ids ) AND (col2, col3) IN ((:a, :b)) AND col4 = :foo")
+	prsr.SetValue("ids", []int{1, 2})
+	prsr.SetValue("a", []int{1})
+	prsr.SetValue("b", []int{2})
+	prsr.SetValue("foo", "foo")
+
+	if err = prsr.Validate(); err != nil {
+		test.Log("Expected slices inside parenthesized lists to be valid. Actual: ", err)
+		test.Fail()
+	}
+
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :ids AND col2 IN (:names) AND col3 = :ids")
+	prsr.SetValue("ids", []int{1, 2})
+	prsr.SetValue("names", []string{"foo"})
+
+	err = prsr.Validate()
+
+	if err == nil || err.Error() != "Unable to expand parameter :ids: a slice value must be used inside a parenthesized list, e.g. IN (:ids)" {
+		test.Log("Expected an error for a slice outside of a parenthesized list. Actual: ", err)
+		test.Fail()
+	}
+
+	// an array value is not expanded, and so may be used anywhere.
+	prsr.SetArrayValue("ids", []int{1, 2})
+
+	if err = prsr.Validate(); err != nil {
+		test.Log("Expected an array value to be valid anywhere. Actual: ", err)
+		test.Fail()
+	}
+
+	// missing values are still reported first.
+	prsr = NewParser("SELECT :ids, :foo")
+	prsr.SetValue("ids", []int{1, 2})
+
+	if err = prsr.Validate(); err == nil || err.Error() != "Missing value for parameter :foo" {
+		test.Log("Expected missing values to be reported before misplaced slices. Actual: ", err)
+		test.Fail()
+	}
+}
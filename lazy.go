@@ -0,0 +1,224 @@
+package npq
+
+import (
+	"database/sql"
+	"net/url"
+	"sync"
+)
+
+// lazyParser is a Parser whose query is only parsed on the first call to any of
+// its methods, after which every call is delegated to the parsed query.
+type lazyParser struct {
+
+	// The function returning the query text, called once by get.
+	source func() string
+
+	// Guards the call to source and the parsing of its query.
+	once sync.Once
+
+	// The parsed query, set by the first call to get.
+	parser *parser
+}
+
+// LazyParser returns a parser for the query text returned by the given [source],
+// which is neither called nor parsed until any method of the parser is first
+// called, e.g. to declare parsed queries as package level variables without
+// parsing every one of them at initialization. The query is parsed in the same
+// way as by NewParser, exactly once, even if the parser is first used from
+// several goroutines at once.
+//
+// Only the parsing is safe for concurrent use; as for any Parser, values should
+// be bound on a Clone, which shares the parsed query instead of parsing it again.
+func LazyParser(source func() string) Parser {
+	return &lazyParser{source: source}
+}
+
+// get returns the parsed query of l, parsing it first if it has not been yet.
+func (l *lazyParser) get() *parser {
+
+	l.once.Do(func() {
+
+		l.parser = newParser(DialectPostgres)
+		l.parser.setQuery(l.source())
+
+		// render once, so that every clone shares the revised query.
+		l.parser.GetParsedQuery()
+	})
+	return l.parser
+}
+
+// The methods of lazyParser delegate to its parsed query, as returned by get.
+
+func (l *lazyParser) GetParsedQuery() string {
+	return l.get().GetParsedQuery()
+}
+
+func (l *lazyParser) GetOriginalQuery() string {
+	return l.get().GetOriginalQuery()
+}
+
+func (l *lazyParser) GetParsedParameters() []interface{} {
+	return l.get().GetParsedParameters()
+}
+
+func (l *lazyParser) GetParsedParametersForNames(parameterNames ...string) []interface{} {
+	return l.get().GetParsedParametersForNames(parameterNames...)
+}
+
+func (l *lazyParser) GetParameterMap() map[string]interface{} {
+	return l.get().GetParameterMap()
+}
+
+func (l *lazyParser) GetNamedArgs() []sql.NamedArg {
+	return l.get().GetNamedArgs()
+}
+
+func (l *lazyParser) Build() (string, []interface{}) {
+	return l.get().Build()
+}
+
+func (l *lazyParser) SQL() string {
+	return l.get().SQL()
+}
+
+func (l *lazyParser) Args() []interface{} {
+	return l.get().Args()
+}
+
+func (l *lazyParser) DebugQuery() string {
+	return l.get().DebugQuery()
+}
+
+func (l *lazyParser) SetValue(parameterName string, parameterValue interface{}) {
+	l.get().SetValue(parameterName, parameterValue)
+}
+
+func (l *lazyParser) SetValueStrict(parameterName string, parameterValue interface{}) error {
+	return l.get().SetValueStrict(parameterName, parameterValue)
+}
+
+func (l *lazyParser) SetArrayValue(parameterName string, parameterValue interface{}) {
+	l.get().SetArrayValue(parameterName, parameterValue)
+}
+
+func (l *lazyParser) SetPositional(values ...interface{}) error {
+	return l.get().SetPositional(values...)
+}
+
+func (l *lazyParser) SetValueAt(position int, parameterValue interface{}) error {
+	return l.get().SetValueAt(position, parameterValue)
+}
+
+func (l *lazyParser) SetValueAtOccurrence(parameterName string, occurrence int, parameterValue interface{}) error {
+	return l.get().SetValueAtOccurrence(parameterName, occurrence, parameterValue)
+}
+
+func (l *lazyParser) With(parameterName string, parameterValue interface{}) Parser {
+	l.get().With(parameterName, parameterValue)
+	return l
+}
+
+func (l *lazyParser) WithValues(parameters map[string]interface{}) Parser {
+	l.get().WithValues(parameters)
+	return l
+}
+
+func (l *lazyParser) SetValuesFromMap(parameters map[string]interface{}) {
+	l.get().SetValuesFromMap(parameters)
+}
+
+func (l *lazyParser) SetValuesFromMaps(parameters ...map[string]interface{}) {
+	l.get().SetValuesFromMaps(parameters...)
+}
+
+func (l *lazyParser) SetValuesFromMapReport(parameters map[string]interface{}) []string {
+	return l.get().SetValuesFromMapReport(parameters)
+}
+
+func (l *lazyParser) SetValuesFromMapStrict(parameters map[string]interface{}) error {
+	return l.get().SetValuesFromMapStrict(parameters)
+}
+
+func (l *lazyParser) SetValuesFromJSON(data []byte) error {
+	return l.get().SetValuesFromJSON(data)
+}
+
+func (l *lazyParser) SetValuesFromNamedArgs(parameters ...sql.NamedArg) {
+	l.get().SetValuesFromNamedArgs(parameters...)
+}
+
+func (l *lazyParser) SetValuesFromURLValues(parameters url.Values, sliceNames ...string) {
+	l.get().SetValuesFromURLValues(parameters, sliceNames...)
+}
+
+func (l *lazyParser) SetValuesFromStruct(parameters interface{}) error {
+	return l.get().SetValuesFromStruct(parameters)
+}
+
+func (l *lazyParser) SetValuesFromStructWithTag(parameters interface{}, tagName string) error {
+	return l.get().SetValuesFromStructWithTag(parameters, tagName)
+}
+
+func (l *lazyParser) ExpandRows(rows interface{}) error {
+	return l.get().ExpandRows(rows)
+}
+
+func (l *lazyParser) Validate() error {
+	return l.get().Validate()
+}
+
+func (l *lazyParser) ParameterNames() []string {
+	return l.get().ParameterNames()
+}
+
+func (l *lazyParser) ParameterCount(parameterName string) int {
+	return l.get().ParameterCount(parameterName)
+}
+
+func (l *lazyParser) HasParameter(parameterName string) bool {
+	return l.get().HasParameter(parameterName)
+}
+
+func (l *lazyParser) NumParameters() int {
+	return l.get().NumParameters()
+}
+
+func (l *lazyParser) Positions(parameterName string) []int {
+	return l.get().Positions(parameterName)
+}
+
+func (l *lazyParser) ParameterOffsets() map[string][]int {
+	return l.get().ParameterOffsets()
+}
+
+func (l *lazyParser) QuotedParameterLikeTokens() []string {
+	return l.get().QuotedParameterLikeTokens()
+}
+
+func (l *lazyParser) GetDialect() Dialect {
+	return l.get().GetDialect()
+}
+
+func (l *lazyParser) ResetValues() {
+	l.get().ResetValues()
+}
+
+func (l *lazyParser) Reset() {
+	l.get().Reset()
+}
+
+func (l *lazyParser) SetDefault(parameterName string, parameterValue interface{}) {
+	l.get().SetDefault(parameterName, parameterValue)
+}
+
+func (l *lazyParser) Clone() Parser {
+	return l.get().Clone()
+}
+
+func (l *lazyParser) Reparse(queryText string) {
+	l.get().Reparse(queryText)
+}
+
+func (l *lazyParser) String() string {
+	return l.get().String()
+}
@@ -0,0 +1,61 @@
+package npq
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestLazyParser(test *testing.T) {
+
+	var prsr Parser
+	var calls int32
+	var waitGroup sync.WaitGroup
+	var queries [8]string
+
+	prsr = LazyParser(func() string {
+		atomic.AddInt32(&calls, 1)
+		return "SELECT * FROM table WHERE col1 = :foo AND col2 = :bar"
+	})
+
+	if atomic.LoadInt32(&calls) != 0 {
+		test.Log("Expected the query not to be parsed before it is used")
+		test.Fail()
+	}
+
+	for i := range queries {
+
+		waitGroup.Add(1)
+
+		go func(i int) {
+
+			defer waitGroup.Done()
+
+			clone := prsr.Clone()
+			clone.SetValue("foo", i)
+			queries[i] = clone.GetParsedQuery()
+		}(i)
+	}
+	waitGroup.Wait()
+
+	if atomic.LoadInt32(&calls) != 1 {
+		test.Log("Expected the query to be parsed exactly once. Actual: ", calls)
+		test.Fail()
+	}
+
+	for _, query := range queries {
+		if query != "SELECT * FROM table WHERE col1 = $1 AND col2 = $2" {
+			test.Log("Expected every clone to share the parsed query. Actual: ", query)
+			test.Fail()
+		}
+	}
+
+	verifyStructParameters("LazyParser", test, prsr.Clone().With("foo", 1).With("bar", 2), []interface{}{1, 2})
+
+	prsr = Merge(prsr, LazyParser(func() string { return " AND col3 = :baz" }))
+
+	if prsr.GetParsedQuery() != "SELECT * FROM table WHERE col1 = $1 AND col2 = $2 AND col3 = $3" {
+		test.Log("Expected lazy parsers to be merged. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+}
@@ -0,0 +1,145 @@
+package npq
+
+// Merge returns a new parser for the query of the given [a] directly followed by
+// the query of the given [b], e.g. to append a filter or a UNION to a query.
+// The placeholders of [b] are numbered after those of [a], and the values,
+// defaults and optional clauses of both are kept, so that GetParsedParameters
+// returns the parameters of [a] followed by those of [b]. The text of the queries
+// is joined as is, so [b] should start with any whitespace or keyword needed.
+//
+// The merged parser uses the dialect, prefix and options of [a]. A name which
+// occurs in both queries refers to the same parameter, and is bound everywhere
+// at once by SetValue, but its placeholders in [b] are never shared with those
+// in [a], even with WithDeduplication. Until it is set again, every occurrence
+// keeps the value it had in its own query. Defaults of [b] only apply to names
+// without a default in [a].
+//
+// Neither [a] nor [b] is modified.
+func Merge(a, b Parser) Parser {
+
+	var first *parser
+	var second *parser
+	var merged *parser
+	var clause optionalClause
+	var joint int
+	var shift int
+
+	first = parserOf(a)
+	second = parserOf(b)
+
+	merged = first.Clone().(*parser)
+	merged.shared = 0
+	merged.originalQuery = first.originalQuery + second.originalQuery
+	merged.revisedQuery = ""
+	merged.rendered = false
+	merged.streamed = first.streamed || second.streamed
+
+	// the last segment of a and the first of b are joined into a single segment.
+	merged.segments = append([]string(nil), first.segments...)
+
+	if len(merged.segments) > 0 && len(second.segments) > 0 {
+		joint = len(merged.segments[len(merged.segments)-1])
+		merged.segments[len(merged.segments)-1] += second.segments[0]
+		merged.segments = append(merged.segments, second.segments[1:]...)
+	} else {
+		merged.segments = append(merged.segments, second.segments...)
+	}
+
+	shift = len(first.positionNames)
+
+	merged.positionNames = append(append([]string(nil), first.positionNames...), second.positionNames...)
+	merged.occurrences = append([]int(nil), first.occurrences...)
+	merged.offsets = append([]int(nil), first.offsets...)
+	merged.statements = append([]int(nil), first.statements...)
+	merged.clauses = append([]optionalClause(nil), first.clauses...)
+
+	for occurrence, position := range second.occurrences {
+		merged.occurrences = append(merged.occurrences, position+shift)
+		merged.offsets = append(merged.offsets, second.offsets[occurrence]+len(first.originalQuery))
+	}
+
+	for _, statement := range second.statements {
+		merged.statements = append(merged.statements, statement+shift)
+	}
+
+	for _, clause = range second.clauses {
+
+		// a clause bounded by the first segment of b now starts after the text of a.
+		if clause.first == 0 {
+			clause.start += joint
+		}
+
+		if clause.last == 0 {
+			clause.end += joint
+		}
+
+		clause.first += len(first.occurrences)
+		clause.last += len(first.occurrences)
+		merged.clauses = append(merged.clauses, clause)
+	}
+
+	merged.positions = positionStore{}
+
+	for position, name := range merged.positionNames {
+		if len(name) > 0 {
+			merged.positions.add(name, position)
+		}
+	}
+
+	merged.quotedTokens = append([]string(nil), first.quotedTokens...)
+	merged.quotedTokens = append(merged.quotedTokens, second.QuotedParameterLikeTokens()...)
+
+	if len(merged.quotedTokens) > len(first.quotedTokens) {
+		merged.quotedTokens = distinctStrings(merged.quotedTokens)
+	}
+
+	merged.parameters = append(append([]interface{}(nil), first.parameters...), second.parameters...)
+	merged.assigned = append(append([]bool(nil), first.assigned...), second.assigned...)
+	merged.expansions = append(append([][]interface{}(nil), first.expansions...), second.expansions...)
+
+	for name, value := range second.defaults {
+
+		if merged.defaults == nil {
+			merged.defaults = make(map[string]interface{})
+		}
+
+		if _, exists := merged.defaults[name]; !exists {
+			merged.defaults[name] = value
+		}
+	}
+
+	merged.applyDefaults()
+	return merged
+}
+
+// parserOf returns the parser implementing the given [prsr], parsing its query
+// first if it was created by LazyParser.
+func parserOf(prsr Parser) *parser {
+
+	if lazy, ok := prsr.(*lazyParser); ok {
+		return lazy.get()
+	}
+	return prsr.(*parser)
+}
+
+// distinctStrings returns the given [values] without repetitions, keeping the
+// first occurrence of each in order. The given slice is reused.
+func distinctStrings(values []string) []string {
+
+	var seen map[string]bool
+	var distinct []string
+
+	seen = make(map[string]bool, len(values))
+	distinct = values[:0]
+
+	for _, value := range values {
+
+		if seen[value] {
+			continue
+		}
+
+		seen[value] = true
+		distinct = append(distinct, value)
+	}
+	return distinct
+}
@@ -0,0 +1,78 @@
+package npq
+
+import (
+	"testing"
+)
+
+func TestMerge(test *testing.T) {
+
+	var first Parser
+	var second Parser
+	var prsr Parser
+	var positions []int
+
+	first = NewParser("SELECT * FROM table WHERE col1 = :foo AND col2 IN (:ids)")
+	first.SetValue("foo", "foo")
+	first.SetValue("ids", []int{1, 2})
+
+	second = NewParser(" AND col3 = :bar [AND col4 = :baz] UNION SELECT * FROM other WHERE col1 = :foo")
+	second.SetValue("bar", 3)
+
+	prsr = Merge(first, second)
+
+	if prsr.GetParsedQuery() != "SELECT * FROM table WHERE col1 = $1 AND col2 IN ($2, $3) AND col3 = $4 [AND col4 = $5] UNION SELECT * FROM other WHERE col1 = $6" {
+		test.Log("Expected the second query to be numbered after the first. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+
+	verifyStructParameters("Merge", test, prsr, []interface{}{"foo", 1, 2, 3, nil, nil})
+
+	if names := prsr.ParameterNames(); len(names) != 4 || names[0] != "foo" || names[3] != "baz" {
+		test.Log("Expected the distinct names of both queries. Actual: ", names)
+		test.Fail()
+	}
+
+	prsr.SetValue("foo", "both")
+	positions = prsr.Positions("foo")
+
+	if len(positions) != 2 || positions[0] != 1 || positions[1] != 6 {
+		test.Log("Expected a shared name to bind both queries. Actual: ", positions)
+		test.Fail()
+	}
+
+	verifyStructParameters("Merge", test, prsr, []interface{}{"both", 1, 2, 3, nil, "both"})
+
+	if offsets := prsr.ParameterOffsets()["bar"]; len(offsets) != 1 || offsets[0] != 68 {
+		test.Log("Expected the offsets of the second query to follow the first. Actual: ", offsets)
+		test.Fail()
+	}
+
+	if first.GetParsedQuery() != "SELECT * FROM table WHERE col1 = $1 AND col2 IN ($2, $3)" || second.NumParameters() != 3 {
+		test.Log("Expected the merged queries not to be modified. Actual: ", first.GetParsedQuery())
+		test.Fail()
+	}
+}
+
+func TestMergeOptions(test *testing.T) {
+
+	var prsr Parser
+
+	prsr = Merge(NewParserWithOptions("SELECT * FROM table WHERE TRUE", WithOptionalClauses()), NewParserWithOptions("[ AND col1 = :foo] [AND col2 = :bar]", WithOptionalClauses()))
+	prsr.SetValue("bar", 1)
+
+	if prsr.GetParsedQuery() != "SELECT * FROM table WHERE TRUE AND col2 = $1" {
+		test.Log("Expected the clauses of the second query to be kept. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+
+	prsr = Merge(NewParserWithOptions("SELECT :a, :b", WithDeduplication()), NewParserWithOptions(" ; SELECT :a", WithDeduplication()))
+	prsr.SetDefault("a", 1)
+	prsr.SetValue("b", 2)
+
+	if prsr.GetParsedQuery() != "SELECT $1, $2 ; SELECT $3" {
+		test.Log("Expected placeholders not to be shared between the queries. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+
+	verifyStructParameters("MergeDefaults", test, prsr, []interface{}{1, 2, 1})
+}
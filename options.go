@@ -0,0 +1,255 @@
+package npq
+
+// Option configures how a parser created by NewParserWithOptions parses its
+// query and binds its values.
+type Option func(p *parser)
+
+// WithDriver makes the parser emit positional placeholders in the syntax of
+// the given [driver]. The default driver is DialectPostgres.
+func WithDriver(driver Driver) Option {
+	return func(p *parser) {
+		p.dialect = driver
+	}
+}
+
+// WithPlaceholderFormat makes the parser emit positional placeholders produced
+// by the given [format] function, which is called with the 1-based index of each
+// placeholder, instead of the syntax of its driver. e.g.
+//
+// 	WithPlaceholderFormat(func(index int) string {
+// 		return ":v" + strconv.Itoa(index)
+// 	})
+func WithPlaceholderFormat(format func(index int) string) Option {
+	return func(p *parser) {
+		p.placeholderFormat = format
+	}
+}
+
+// WithPrefix makes the parser recognize named parameters starting with the
+// given [prefix] character instead of ":", e.g. '@' for "@name".
+//
+// With an '@' prefix, the Postgres operators "@@", "@>" and "<@" are kept as
+// operators, in the same way as a "::" cast, so that only "@" followed by a
+// name starts a parameter.
+func WithPrefix(prefix rune) Option {
+	return func(p *parser) {
+		p.prefix = prefix
+	}
+}
+
+// WithDollarNames makes the parser recognize named parameters starting with "$",
+// as used by some template engines, while keeping a "$" followed by a digit as
+// an existing numbered placeholder, so that "$name = $1" only has the parameter
+// "name". The existing placeholders are kept as they are, and the named
+// parameters are numbered after the highest of them, e.g. "$name = $2 OR $1"
+// becomes "$3 = $2 OR $1". The values of the existing placeholders are not part
+// of GetParsedParameters, and must be passed to the driver before them.
+func WithDollarNames() Option {
+	return func(p *parser) {
+		p.prefix = '$'
+		p.numberedPassthrough = true
+	}
+}
+
+// WithDeduplication makes every occurrence of a repeated parameter refer to the
+// same positional parameter, e.g. ":foo AND :foo" becomes "$1 AND $1", so that
+// its value is passed only once. The positional parameters are still ordered to
+// match the placeholder numbers in the revised query.
+//
+// Deduplication only applies to dialects whose placeholders may be reused, such
+// as DialectPostgres and DialectSQLServer. For DialectMySQL and DialectOracle,
+// whose placeholders are bound by position, every occurrence keeps its own
+// positional parameter, so the option has no effect.
+func WithDeduplication() Option {
+	return func(p *parser) {
+		p.deduplicate = true
+	}
+}
+
+// WithCaseInsensitiveNames makes the parser match parameter names regardless of
+// case, so that SetValue("userid", v) binds ":UserID". Parameter names are lower
+// cased by the parser, e.g. as returned by ParameterNames.
+func WithCaseInsensitiveNames() Option {
+	return func(p *parser) {
+		p.caseInsensitive = true
+	}
+}
+
+// WithDuplicateFieldCheck makes SetValuesFromStruct return an error when two
+// fields of a struct resolve to the same parameter name, e.g. one by its tag and
+// one by its field name, instead of letting the later field overwrite the other.
+func WithDuplicateFieldCheck() Option {
+	return func(p *parser) {
+		p.checkDuplicateFields = true
+	}
+}
+
+// WithJSONTagFallback makes SetValuesFromStruct name a field without a
+// sqlParameterName tag by the name in its json tag, ignoring options such as
+// ",omitempty", before falling back to the field name. A field tagged with
+// `json:"-"` is still bound, by its field name.
+func WithJSONTagFallback() Option {
+	return func(p *parser) {
+		p.jsonTagFallback = true
+	}
+}
+
+// WithPositionalPassthrough makes the parser treat every "?" in the original
+// query as an anonymous positional parameter, which takes its own position among
+// the named parameters, so that queries mixing both styles are bound in order.
+// Anonymous parameters can only be set by position, e.g. with SetPositional.
+//
+// This must not be used with queries containing the Postgres "?" JSON operators.
+func WithPositionalPassthrough() Option {
+	return func(p *parser) {
+		p.positionalPassthrough = true
+	}
+}
+
+// WithNilNormalization makes the parser bind every typed nil value, such as a
+// nil []byte, map or pointer, as an untyped nil, so that drivers always treat it
+// as SQL NULL. A nil slice is then bound as NULL instead of being expanded.
+func WithNilNormalization() Option {
+	return func(p *parser) {
+		p.normalizeNils = true
+	}
+}
+
+// WithoutQuoteHandling makes the parser treat single quotes, double quotes and
+// Postgres dollar quotes as ordinary characters, instead of skipping the string
+// literals and quoted identifiers they delimit, which saves scanning them.
+//
+// This is only safe for trusted queries which are known not to contain a
+// parameter prefix inside quotes: a prefix inside a literal such as 'a:b' is
+// then parsed as a parameter, changing the meaning of the query. It is off by
+// default, and NewParserStrictWithOptions no longer reports unterminated quotes.
+func WithoutQuoteHandling() Option {
+	return func(p *parser) {
+		p.ignoreQuotes = true
+	}
+}
+
+// WithOptionalClauses makes square brackets in the query delimit optional
+// clauses, which are omitted from the revised query, together with their
+// parameters, if none of their parameters are set, e.g.
+//
+// 	SELECT * FROM table WHERE 1 = 1 [AND status = :status] [AND id IN (:ids)]
+//
+// becomes "SELECT * FROM table WHERE 1 = 1  AND id IN ($1)" if only "ids" is set,
+// keeping the whitespace around the omitted clause.
+// A parameter with a default counts as set. The placeholders which remain are
+// renumbered, and the parameters of omitted clauses are not reported by Validate.
+//
+// A bracket directly after a name, a closing bracket or parenthesis, or a
+// double quote is kept as a subscript, e.g. "col[1]", as are brackets nested
+// inside a clause. Clauses cannot be nested.
+func WithOptionalClauses() Option {
+	return func(p *parser) {
+		p.optionalClauses = true
+	}
+}
+
+// WithStatementNumbering makes the parser number the placeholders of every
+// statement of a query separated by semicolons from 1 again, e.g. "SELECT :a;
+// SELECT :b" becomes "SELECT $1; SELECT $1", for drivers which prepare each
+// statement separately. Semicolons inside quotes and comments do not separate
+// statements. The positional parameters of all statements are still returned
+// in one slice, in order, and deduplication only applies within a statement.
+func WithStatementNumbering() Option {
+	return func(p *parser) {
+		p.statementNumbering = true
+	}
+}
+
+// WithValuerResolution makes the parser call the Value method of every value
+// implementing driver.Valuer when it is bound, and bind the result instead, so
+// that custom types are normalized before they reach the driver. A value whose
+// Value method returns an error is bound unchanged.
+func WithValuerResolution() Option {
+	return func(p *parser) {
+		p.resolveValuers = true
+	}
+}
+
+// WithNullUnwrapping makes the parser bind every value of the standard
+// sql.NullString, sql.NullInt64, sql.NullBool, sql.NullFloat64 and sql.NullTime
+// types as the value it contains, or as nil if it is not valid, for drivers which
+// do not call their Value method. Pointers to these types are bound as they are.
+func WithNullUnwrapping() Option {
+	return func(p *parser) {
+		p.unwrapNulls = true
+	}
+}
+
+// WithUnderlyingTypes makes the parser bind every value of a named boolean,
+// numeric or string type, such as "type Status int", as a value of its built-in
+// type, e.g. an int, for drivers which reject named types. Values implementing
+// driver.Valuer are bound as they are, and slices are not converted.
+func WithUnderlyingTypes() Option {
+	return func(p *parser) {
+		p.underlyingTypes = true
+	}
+}
+
+// WithBlankStringsAsNull makes the parser bind every string value which is empty
+// or contains only whitespace as nil, i.e. SQL NULL, e.g. for optional form fields.
+// Only values of a string kind are affected; a pointer to a blank string is not.
+func WithBlankStringsAsNull() Option {
+	return func(p *parser) {
+		p.blankStringsAsNull = true
+	}
+}
+
+// WithNameValidator makes the parser call the given [validate] function with
+// every parameter name of its query, e.g. to enforce naming conventions or a
+// maximum length. Names are only rejected by NewParserStrictWithOptions, which
+// returns the first error of validate; other constructors accept every name.
+//
+// 	WithNameValidator(func(parameterName string) error {
+// 		if len(parameterName) > 30 {
+// 			return errors.New("name is too long")
+// 		}
+// 		return nil
+// 	})
+func WithNameValidator(validate func(parameterName string) error) Option {
+	return func(p *parser) {
+		p.nameValidator = validate
+	}
+}
+
+// NewParserWithOptions creates a new named parameter query in the same way as
+// NewParser, configured by the given [options].
+func NewParserWithOptions(queryText string, options ...Option) Parser {
+
+	p := newParser(DialectPostgres)
+
+	for _, option := range options {
+		option(p)
+	}
+
+	p.setQuery(queryText)
+
+	return p
+}
+
+// NewParserStrictWithOptions creates a new named parameter query in the same way
+// as NewParserWithOptions, but returns an error if the query is malformed, in the
+// same way as NewParserStrict, or if a parameter name is rejected by the function
+// given to WithNameValidator.
+func NewParserStrictWithOptions(queryText string, options ...Option) (Parser, error) {
+
+	var err error
+
+	p := newParser(DialectPostgres)
+
+	for _, option := range options {
+		option(p)
+	}
+
+	err = p.setQuery(queryText)
+
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
@@ -0,0 +1,751 @@
+package npq
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrefixOption(test *testing.T) {
+
+	var prsr Parser
+
+	prsr = NewParserWithOptions("SELECT * FROM table WHERE col1 = @foo AND col2 = ':literal' AND col3 = @bar AND col4 = @foo", WithPrefix('@'))
+	prsr.SetValue("foo", "something")
+	prsr.SetValue("bar", "else")
+
+	if prsr.GetParsedQuery() != "SELECT * FROM table WHERE col1 = $1 AND col2 = ':literal' AND col3 = $2 AND col4 = $3" {
+		test.Log("Expected '@' prefixed parameters to be replaced. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+
+	verifyStructParameters("PrefixOption", test, prsr, []interface{}{
+		"something",
+		"else",
+		"something",
+	})
+
+	prsr = NewParserWithOptions("SELECT * FROM table WHERE col1 = :foo AND col2 = @foo", WithPrefix('@'), WithDriver(DriverMySQL))
+
+	if prsr.GetParsedQuery() != "SELECT * FROM table WHERE col1 = :foo AND col2 = ?" {
+		test.Log("Expected colons to be ordinary characters with an '@' prefix. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+
+	if prsr.Validate() == nil || prsr.Validate().Error() != "Missing value for parameter @foo" {
+		test.Log("Expected validation errors to use the configured prefix. Actual: ", prsr.Validate())
+		test.Fail()
+	}
+}
+
+func TestPrefixOperators(test *testing.T) {
+
+	var prsr Parser
+	var err error
+
+	prsr, err = NewParserStrictWithOptions("SELECT * FROM docs WHERE to_tsvector(body) @@to_tsquery(@query) AND tags @> @tags AND @@ ts_query(@query) AND @tags <@ tags", WithPrefix('@'))
+
+	if err != nil {
+		test.Log("Expected text search and containment operators not to be parameters. Actual: ", err)
+		test.FailNow()
+	}
+
+	if prsr.GetParsedQuery() != "SELECT * FROM docs WHERE to_tsvector(body) @@to_tsquery($1) AND tags @> $2 AND @@ ts_query($3) AND $4 <@ tags" {
+		test.Log("Unexpected parsed output for operators: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+
+	if names := prsr.ParameterNames(); len(names) != 2 || names[0] != "query" || names[1] != "tags" {
+		test.Log("Expected only the parameters to be named. Actual: ", names)
+		test.Fail()
+	}
+}
+
+// bindPositional substitutes the given [parameters] for the numbered "$N"
+// placeholders of the given [query], as a database would bind them.
+func bindPositional(query string, parameters []interface{}) string {
+
+	for index := len(parameters); index > 0; index-- {
+		query = strings.Replace(query, "$"+strconv.Itoa(index), fmt.Sprint(parameters[index-1]), -1)
+	}
+	return query
+}
+
+func TestDeduplicationOption(test *testing.T) {
+
+	var prsr Parser
+	var query string
+	var parameters []interface{}
+
+	prsr = NewParserWithOptions("SELECT * FROM table WHERE col1 = :foo AND col2 = :bar AND col3 = :foo AND col4 IN (:ids) AND col5 = :baz AND col6 = :bar", WithDeduplication())
+	prsr.SetValue("foo", "foo")
+	prsr.SetValue("bar", "bar")
+	prsr.SetValue("baz", "baz")
+
+	if prsr.GetParsedQuery() != "SELECT * FROM table WHERE col1 = $1 AND col2 = $2 AND col3 = $1 AND col4 IN ($3) AND col5 = $4 AND col6 = $2" {
+		test.Log("Expected repeated parameters to reuse placeholders. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+
+	if prsr.ParameterCount("foo") != 2 || prsr.ParameterCount("ids") != 1 {
+		test.Log("Expected deduplicated parameters to still count every occurrence")
+		test.Fail()
+	}
+
+	if prsr.Validate() == nil || prsr.Validate().Error() != "Missing value for parameter :ids" {
+		test.Log("Expected only :ids to be missing. Actual: ", prsr.Validate())
+		test.Fail()
+	}
+
+	prsr.SetValue("ids", []int{7, 8})
+	query, parameters = prsr.Build()
+
+	if query != "SELECT * FROM table WHERE col1 = $1 AND col2 = $2 AND col3 = $1 AND col4 IN ($3, $4) AND col5 = $5 AND col6 = $2" {
+		test.Log("Expected expanded placeholders to be renumbered. Actual: ", query)
+		test.Fail()
+	}
+
+	if bindPositional(query, parameters) != "SELECT * FROM table WHERE col1 = foo AND col2 = bar AND col3 = foo AND col4 IN (7, 8) AND col5 = baz AND col6 = bar" {
+		test.Log("Expected parameters to align with placeholders. Actual: ", bindPositional(query, parameters))
+		test.Fail()
+	}
+}
+
+func TestCaseInsensitiveNamesOption(test *testing.T) {
+
+	var prsr Parser
+
+	prsr = NewParserWithOptions("SELECT * FROM table WHERE col1 = :UserID AND col2 = :userid AND col3 = :Name", WithCaseInsensitiveNames())
+	prsr.SetValue("userid", 5)
+	prsr.SetValuesFromMap(map[string]interface{}{
+		"NAME": "alice",
+	})
+
+	verifyStructParameters("CaseInsensitiveNames", test, prsr, []interface{}{
+		5,
+		5,
+		"alice",
+	})
+
+	if prsr.ParameterCount("USERID") != 2 || len(prsr.ParameterNames()) != 2 || prsr.ParameterNames()[0] != "userid" {
+		test.Log("Expected differently cased names to be the same parameter. Actual: ", prsr.ParameterNames())
+		test.Fail()
+	}
+
+	if prsr.SetValueStrict("Userid", 6) != nil {
+		test.Log("Expected SetValueStrict to match regardless of case")
+		test.Fail()
+	}
+
+	// the default remains case sensitive.
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :UserID")
+	prsr.SetValue("userid", 5)
+
+	verifyStructParameters("CaseSensitiveNames", test, prsr, []interface{}{
+		nil,
+	})
+}
+
+type DuplicateFieldParameterTest struct {
+	Foo   string
+	Other string `sqlParameterName:"Foo"`
+	Bar   string
+}
+
+func TestDuplicateFieldCheckOption(test *testing.T) {
+
+	var prsr Parser
+	var duplicateParam DuplicateFieldParameterTest
+	var err error
+
+	duplicateParam.Foo = "foo"
+	duplicateParam.Other = "other"
+	duplicateParam.Bar = "bar"
+
+	prsr = NewParserWithOptions("SELECT * FROM table WHERE col1 = :Foo AND col2 = :Bar", WithDuplicateFieldCheck())
+	err = prsr.SetValuesFromStruct(duplicateParam)
+
+	if err == nil || err.Error() != "Unable to add query values from parameter: fields Foo and Other both bind parameter :Foo" {
+		test.Log("Expected an error for fields binding the same parameter. Actual: ", err)
+		test.Fail()
+	}
+
+	verifyStructParameters("DuplicateFieldsNotBound", test, prsr, []interface{}{
+		nil,
+		nil,
+	})
+
+	err = prsr.SetValuesFromStruct(SingleParameterTest{Foo: "foo", Bar: "bar"})
+
+	if err != nil {
+		test.Log("Expected no error for a struct without duplicate names. Actual: ", err)
+		test.Fail()
+	}
+
+	// without the option, the later field wins.
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :Foo AND col2 = :Bar")
+	err = prsr.SetValuesFromStruct(duplicateParam)
+
+	if err != nil {
+		test.Log("Expected duplicate names to be allowed by default. Actual: ", err)
+		test.Fail()
+	}
+
+	verifyStructParameters("DuplicateFieldsOverwrite", test, prsr, []interface{}{
+		"other",
+		"bar",
+	})
+}
+
+func TestPositionalPassthroughOption(test *testing.T) {
+
+	var prsr Parser
+
+	prsr = NewParserWithOptions("SELECT * FROM table WHERE col1 = ? AND col2 = :foo AND col3 = '?' AND col4 = ? AND col5 = :foo", WithPositionalPassthrough())
+
+	if prsr.GetParsedQuery() != "SELECT * FROM table WHERE col1 = $1 AND col2 = $2 AND col3 = '?' AND col4 = $3 AND col5 = $4" {
+		test.Log("Expected positional placeholders to be numbered with named parameters. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+
+	prsr.SetValue("foo", "foo")
+
+	if prsr.Validate() == nil || prsr.Validate().Error() != "Missing values for parameters ?1, ?3" {
+		test.Log("Expected unset anonymous parameters to be reported by position. Actual: ", prsr.Validate())
+		test.Fail()
+	}
+
+	prsr.SetPositional("first", "foo", "third")
+
+	verifyStructParameters("PositionalPassthrough", test, prsr, []interface{}{
+		"first",
+		"foo",
+		"third",
+		"foo",
+	})
+
+	// "?" is left alone by default.
+	prsr = NewParserForDriver("SELECT * FROM table WHERE col1 = ? AND col2 = :foo", DriverMySQL)
+
+	if len(prsr.GetParsedParameters()) != 1 {
+		test.Log("Expected '?' not to be a parameter by default")
+		test.Fail()
+	}
+}
+
+func TestPlaceholderFormatOption(test *testing.T) {
+
+	var prsr Parser
+
+	format := func(index int) string {
+		return ":v" + strconv.Itoa(index)
+	}
+
+	prsr = NewParserWithOptions("SELECT * FROM table WHERE col1 = :foo AND col2 IN (:ids) AND col3 = :foo", WithPlaceholderFormat(format))
+
+	if prsr.GetParsedQuery() != "SELECT * FROM table WHERE col1 = :v1 AND col2 IN (:v2) AND col3 = :v3" {
+		test.Log("Expected placeholders from the format function. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+
+	prsr.SetValue("ids", []int{1, 2})
+
+	if prsr.Clone().GetParsedQuery() != "SELECT * FROM table WHERE col1 = :v1 AND col2 IN (:v2) AND col3 = :v3" {
+		test.Log("Expected a clone to keep the format function. Actual: ", prsr.Clone().GetParsedQuery())
+		test.Fail()
+	}
+
+	if prsr.GetParsedQuery() != "SELECT * FROM table WHERE col1 = :v1 AND col2 IN (:v2, :v3) AND col3 = :v4" {
+		test.Log("Expected expanded placeholders from the format function. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+}
+
+type upperValuer string
+
+func (value upperValuer) Value() (driver.Value, error) {
+	return strings.ToUpper(string(value)), nil
+}
+
+type failingValuer struct{}
+
+func (value *failingValuer) Value() (driver.Value, error) {
+	return nil, errors.New("failed")
+}
+
+func TestValuerResolutionOption(test *testing.T) {
+
+	var prsr Parser
+	var failing *failingValuer
+
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :foo")
+	prsr.SetValue("foo", upperValuer("foo"))
+
+	verifyStructParameters("ValuerUnresolved", test, prsr, []interface{}{
+		upperValuer("foo"),
+	})
+
+	prsr = NewParserWithOptions("SELECT * FROM table WHERE col1 = :foo AND col2 = :bar AND col3 = :baz", WithValuerResolution())
+	prsr.SetValue("foo", upperValuer("foo"))
+	prsr.SetValue("bar", failing)
+
+	failing = &failingValuer{}
+	prsr.SetValue("baz", failing)
+
+	verifyStructParameters("ValuerResolved", test, prsr, []interface{}{
+		"FOO",
+		nil,
+		failing,
+	})
+
+	prsr = prsr.Clone()
+	prsr.SetValue("foo", upperValuer("bar"))
+
+	verifyStructParameters("ValuerResolvedClone", test, prsr, []interface{}{
+		"BAR",
+		nil,
+		nil,
+	})
+
+	// expanded elements are resolved as well, and typed nil elements normalized.
+	prsr = NewParserWithOptions("SELECT * FROM table WHERE col1 IN (:values)", WithValuerResolution(), WithNilNormalization())
+	prsr.SetValue("values", []interface{}{upperValuer("foo"), []byte(nil)})
+
+	verifyStructParameters("ValuerResolvedExpanded", test, prsr, []interface{}{
+		"FOO",
+		nil,
+	})
+}
+
+type enumInt int
+
+type enumString string
+
+type enumFloat float32
+
+func TestUnderlyingTypesOption(test *testing.T) {
+
+	var prsr Parser
+
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :foo")
+	prsr.SetValue("foo", enumInt(1))
+
+	verifyStructParameters("NamedTypesKept", test, prsr, []interface{}{
+		enumInt(1),
+	})
+
+	prsr = NewParserWithOptions("SELECT * FROM table WHERE col1 = :int AND col2 = :string AND col3 = :float AND col4 = :valuer AND col5 = :builtin", WithUnderlyingTypes())
+	prsr.SetValue("int", enumInt(1))
+	prsr.SetValue("string", enumString("active"))
+	prsr.SetValue("float", enumFloat(1.5))
+	prsr.SetValue("valuer", upperValuer("foo"))
+	prsr.SetValue("builtin", int64(2))
+
+	verifyStructParameters("NamedTypesConverted", test, prsr, []interface{}{
+		1,
+		"active",
+		float32(1.5),
+		upperValuer("foo"),
+		int64(2),
+	})
+
+	// expanded elements and defaults are converted as well.
+	prsr = NewParserWithOptions("SELECT * FROM table WHERE col1 IN (:ints) AND col2 = :default", WithUnderlyingTypes())
+	prsr.SetValue("ints", []enumInt{1, 2})
+	prsr.SetDefault("default", enumString("active"))
+
+	verifyStructParameters("NamedTypesExpanded", test, prsr, []interface{}{
+		1,
+		2,
+		"active",
+	})
+}
+
+func TestNullUnwrappingOption(test *testing.T) {
+
+	var prsr Parser
+	var now time.Time
+
+	now = time.Now()
+
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :foo")
+	prsr.SetValue("foo", sql.NullString{String: "foo", Valid: true})
+
+	verifyStructParameters("NullTypesKept", test, prsr, []interface{}{
+		sql.NullString{String: "foo", Valid: true},
+	})
+
+	prsr = NewParserWithOptions("SELECT :string, :int, :bool, :float, :time, :invalid, :pointer, :other", WithNullUnwrapping())
+	prsr.SetValue("string", sql.NullString{String: "foo", Valid: true})
+	prsr.SetValue("int", sql.NullInt64{Int64: 1, Valid: true})
+	prsr.SetValue("bool", sql.NullBool{Bool: true, Valid: true})
+	prsr.SetValue("float", sql.NullFloat64{Float64: 1.5, Valid: true})
+	prsr.SetValue("time", sql.NullTime{Time: now, Valid: true})
+	prsr.SetValue("invalid", sql.NullString{String: "foo"})
+	prsr.SetValue("pointer", &sql.NullInt64{Int64: 2, Valid: true})
+	prsr.SetValue("other", "bar")
+
+	verifyStructParameters("NullTypesUnwrapped", test, prsr, []interface{}{
+		"foo",
+		int64(1),
+		true,
+		1.5,
+		now,
+		nil,
+		prsr.GetParsedParameters()[6],
+		"bar",
+	})
+
+	if _, ok := prsr.GetParsedParameters()[6].(*sql.NullInt64); !ok {
+		test.Log("Expected a pointer to a null type to be bound as it is")
+		test.Fail()
+	}
+
+	prsr = NewParserWithOptions("SELECT * FROM table WHERE col1 IN (:names) AND col2 = :default", WithNullUnwrapping(), WithBlankStringsAsNull())
+	prsr.SetValue("names", []interface{}{sql.NullString{String: "foo", Valid: true}, sql.NullString{}, " "})
+	prsr.SetDefault("default", sql.NullInt64{Int64: 3, Valid: true})
+
+	verifyStructParameters("NullTypesExpanded", test, prsr, []interface{}{
+		"foo",
+		nil,
+		nil,
+		int64(3),
+	})
+}
+
+type blankString string
+
+func TestBlankStringsAsNullOption(test *testing.T) {
+
+	var prsr Parser
+	var blank string
+
+	blank = " "
+
+	prsr = NewParser("SELECT :a, :b")
+	prsr.SetValue("a", "")
+	prsr.SetValue("b", " \t")
+
+	verifyStructParameters("BlankStringsKept", test, prsr, []interface{}{
+		"",
+		" \t",
+	})
+
+	prsr = NewParserWithOptions("SELECT :a, :b, :c, :d, :e", WithBlankStringsAsNull())
+	prsr.SetValue("a", "")
+	prsr.SetValue("b", " \t\n")
+	prsr.SetValue("c", blankString(" "))
+	prsr.SetValue("d", " foo ")
+	prsr.SetValue("e", &blank)
+
+	verifyStructParameters("BlankStringsAsNull", test, prsr, []interface{}{
+		nil,
+		nil,
+		nil,
+		" foo ",
+		&blank,
+	})
+
+	if prsr.Validate() != nil {
+		test.Log("Expected blank strings to count as assigned. Actual: ", prsr.Validate())
+		test.Fail()
+	}
+}
+
+func TestStatementNumberingOption(test *testing.T) {
+
+	var prsr Parser
+
+	query := "INSERT INTO table VALUES (:a, :b); -- ; :c\nUPDATE table SET col1 = ';' WHERE col2 IN (:ids) AND col3 = :a;; DELETE FROM table WHERE col1 = :a"
+
+	prsr = NewParserWithOptions(query, WithStatementNumbering())
+	prsr.SetValue("a", 1)
+	prsr.SetValue("ids", []int{2, 3})
+
+	if prsr.GetParsedQuery() != "INSERT INTO table VALUES ($1, $2); -- ; :c\nUPDATE table SET col1 = ';' WHERE col2 IN ($1, $2) AND col3 = $3;; DELETE FROM table WHERE col1 = $1" {
+		test.Log("Expected placeholders to be numbered per statement. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+
+	verifyStructParameters("StatementNumbering", test, prsr, []interface{}{
+		1, nil, 2, 3, 1, 1,
+	})
+
+	if positions := prsr.Positions("a"); len(positions) != 3 || positions[0] != 1 || positions[1] != 3 || positions[2] != 1 {
+		test.Log("Expected positions numbered per statement. Actual: ", positions)
+		test.Fail()
+	}
+
+	prsr = NewParserWithOptions("SELECT :a, :a; SELECT :a", WithStatementNumbering(), WithDeduplication())
+	prsr.SetValue("a", 1)
+
+	if prsr.GetParsedQuery() != "SELECT $1, $1; SELECT $1" {
+		test.Log("Expected deduplication within each statement. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+
+	verifyStructParameters("StatementDeduplication", test, prsr, []interface{}{
+		1, 1,
+	})
+
+	if NewParser("SELECT :a; SELECT :b").GetParsedQuery() != "SELECT $1; SELECT $2" {
+		test.Log("Expected continuous numbering by default")
+		test.Fail()
+	}
+}
+
+func TestDollarNamesOption(test *testing.T) {
+
+	var prsr Parser
+
+	prsr = NewParserWithOptions("SELECT * FROM table WHERE col1 = $name AND col2 = $2 AND col3 = '$other' AND col4 = $1 AND col5 = $name2 AND col6 = $1", WithDollarNames())
+
+	if prsr.GetParsedQuery() != "SELECT * FROM table WHERE col1 = $3 AND col2 = $2 AND col3 = '$other' AND col4 = $1 AND col5 = $4 AND col6 = $1" {
+		test.Log("Expected existing placeholders to be kept, and the dollar names numbered after them. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+
+	prsr.SetValue("name", "foo")
+	prsr.SetValue("name2", "bar")
+
+	verifyStructParameters("DollarNames", test, prsr, []interface{}{
+		"foo",
+		"bar",
+	})
+
+	if positions := prsr.Positions("name2"); len(positions) != 1 || positions[0] != 4 {
+		test.Log("Expected the positions to follow the existing placeholders. Actual: ", positions)
+		test.Fail()
+	}
+
+	if len(prsr.ParameterNames()) != 2 || prsr.ParameterNames()[0] != "name" || prsr.ParameterNames()[1] != "name2" {
+		test.Log("Expected the dollar names as parameters. Actual: ", prsr.ParameterNames())
+		test.Fail()
+	}
+
+	// the prefix alone still treats digits as part of a name.
+	prsr = NewParserWithOptions("SELECT $1", WithPrefix('$'))
+
+	if prsr.ParameterCount("1") != 1 {
+		test.Log("Expected a digit name with a plain dollar prefix. Actual: ", prsr.ParameterNames())
+		test.Fail()
+	}
+}
+
+func TestNameValidatorOption(test *testing.T) {
+
+	var prsr Parser
+	var err error
+
+	validate := func(parameterName string) error {
+
+		if len(parameterName) > 5 {
+			return errors.New("name is too long")
+		}
+		return nil
+	}
+
+	prsr, err = NewParserStrictWithOptions("SELECT :short, :toolong", WithNameValidator(validate))
+
+	if err == nil || prsr != nil || !strings.Contains(err.Error(), ":toolong at byte 15") {
+		test.Log("Expected an error for a rejected name. Actual: ", err)
+		test.Fail()
+	}
+
+	prsr, err = NewParserStrictWithOptions("SELECT :short, @ok", WithNameValidator(validate), WithPrefix('@'))
+
+	if err != nil || prsr.ParameterCount("ok") != 1 {
+		test.Log("Expected every accepted name to parse. Actual: ", err)
+		test.Fail()
+	}
+
+	_, err = NewParserStrictWithOptions("SELECT 'foo")
+
+	if err == nil {
+		test.Log("Expected an error for a malformed query without a validator")
+		test.Fail()
+	}
+
+	// the lenient constructor parses every name.
+	prsr = NewParserWithOptions("SELECT :toolong", WithNameValidator(validate))
+
+	if prsr.ParameterCount("toolong") != 1 {
+		test.Log("Expected a rejected name to still be parsed leniently. Actual: ", prsr.ParameterNames())
+		test.Fail()
+	}
+}
+
+type JSONTagParameterTest struct {
+	ID       int    `json:"id,omitempty"`
+	Name     string `json:"name" sqlParameterName:"title"`
+	Secret   string `json:"-"`
+	Untagged string
+}
+
+func TestJSONTagFallbackOption(test *testing.T) {
+
+	var prsr Parser
+	var parameters JSONTagParameterTest
+
+	parameters = JSONTagParameterTest{ID: 1, Name: "foo", Secret: "bar", Untagged: "baz"}
+
+	prsr = NewParser("SELECT :id, :ID, :title, :name, :Secret, :Untagged")
+	prsr.SetValuesFromStruct(parameters)
+
+	verifyStructParameters("JSONTagsIgnored", test, prsr, []interface{}{
+		nil, 1, "foo", nil, "bar", "baz",
+	})
+
+	prsr = NewParserWithOptions("SELECT :id, :ID, :title, :name, :Secret, :Untagged", WithJSONTagFallback())
+	prsr.SetValuesFromStruct(parameters)
+
+	verifyStructParameters("JSONTagFallback", test, prsr, []interface{}{
+		1, nil, "foo", nil, "bar", "baz",
+	})
+}
+
+func TestNilNormalizationOption(test *testing.T) {
+
+	var prsr Parser
+	var parameters []interface{}
+	var bytes []byte
+	var values map[string]int
+	var pointer *int
+	var err error
+	var ids []int
+
+	prsr = NewParserWithOptions("SELECT :bytes, :values, :pointer, :err, :ids, :zero", WithNilNormalization())
+	prsr.SetValue("bytes", bytes)
+	prsr.SetValue("values", values)
+	prsr.SetValue("pointer", pointer)
+	prsr.SetValue("err", err)
+	prsr.SetValue("ids", ids)
+	prsr.SetValue("zero", 0)
+
+	if prsr.GetParsedQuery() != "SELECT $1, $2, $3, $4, $5, $6" {
+		test.Log("Expected a nil slice not to be expanded. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+
+	parameters = prsr.GetParsedParameters()
+
+	for position, parameter := range parameters[:5] {
+		if parameter != nil {
+			test.Log("Expected an untyped nil at position ", position, ". Actual: ", fmt.Sprintf("%#v", parameter))
+			test.Fail()
+		}
+	}
+
+	if parameters[5] != 0 {
+		test.Log("Expected a value which is not nil to be kept. Actual: ", parameters[5])
+		test.Fail()
+	}
+
+	prsr = NewParser("SELECT :bytes")
+	prsr.SetValue("bytes", bytes)
+
+	if prsr.GetParsedParameters()[0] == nil {
+		test.Log("Expected a typed nil to be kept by default")
+		test.Fail()
+	}
+}
+
+func TestOptionalClausesOption(test *testing.T) {
+
+	var prsr Parser
+	var err error
+
+	query := "SELECT * FROM table WHERE col1 = :foo[ AND col2 = :status][ AND col3[1] IN (:ids) AND col4 = :bar] ORDER BY col5"
+
+	prsr = NewParserWithOptions(query, WithOptionalClauses())
+	prsr.SetValue("foo", "foo")
+
+	if prsr.GetParsedQuery() != "SELECT * FROM table WHERE col1 = $1 ORDER BY col5" {
+		test.Log("Expected the unset clauses to be omitted. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+
+	verifyStructParameters("OptionalClausesOmitted", test, prsr, []interface{}{
+		"foo",
+	})
+
+	if prsr.Validate() != nil || prsr.NumParameters() != 1 || len(prsr.Positions("foo")) != 1 {
+		test.Log("Expected omitted parameters not to be required. Actual: ", prsr.Validate())
+		test.Fail()
+	}
+
+	prsr.SetValue("ids", []int{1, 2})
+
+	if prsr.GetParsedQuery() != "SELECT * FROM table WHERE col1 = $1 AND col3[1] IN ($2, $3) AND col4 = $4 ORDER BY col5" {
+		test.Log("Expected a clause with a set parameter to be kept. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+
+	verifyStructParameters("OptionalClausesKept", test, prsr, []interface{}{
+		"foo", 1, 2, nil,
+	})
+
+	if positions := prsr.Positions("bar"); len(positions) != 1 || positions[0] != 4 {
+		test.Log("Expected the remaining placeholders to be renumbered. Actual: ", positions)
+		test.Fail()
+	}
+
+	prsr.ResetValues()
+	prsr.SetDefault("status", "active")
+
+	if prsr.GetParsedQuery() != "SELECT * FROM table WHERE col1 = $1 AND col2 = $2 ORDER BY col5" {
+		test.Log("Expected a parameter with a default to keep its clause. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+
+	// without the option, brackets are part of the query.
+	prsr = NewParser("SELECT [col1] FROM table WHERE col2 = :foo")
+
+	if prsr.GetParsedQuery() != "SELECT [col1] FROM table WHERE col2 = $1" {
+		test.Log("Expected brackets to be kept by default. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+
+	_, err = NewParserStrictWithOptions("SELECT 1 [AND col1 = :foo", WithOptionalClauses())
+
+	if err == nil {
+		test.Log("Expected an error for an unterminated optional clause")
+		test.Fail()
+	}
+}
+
+func TestWithoutQuoteHandlingOption(test *testing.T) {
+
+	var prsr Parser
+	var err error
+
+	prsr, err = NewParserStrictWithOptions("SELECT 'it's :foo', \"col:bar\", $$:baz$$ FROM table", WithoutQuoteHandling())
+
+	if err != nil {
+		test.Log("Expected unbalanced quotes to be ordinary characters. Actual: ", err)
+		test.FailNow()
+	}
+
+	if prsr.GetParsedQuery() != "SELECT 'it's $1', \"col$2\", $$$3$$ FROM table" {
+		test.Log("Expected parameters inside quotes to be parsed. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+
+	if prsr.Clone().GetParsedQuery() != prsr.GetParsedQuery() {
+		test.Log("Expected a clone to keep the option. Actual: ", prsr.Clone().GetParsedQuery())
+		test.Fail()
+	}
+
+	// by default, quotes are still skipped.
+	prsr = NewParser("SELECT ':foo', :bar")
+
+	if prsr.GetParsedQuery() != "SELECT ':foo', $1" {
+		test.Log("Expected quotes to be skipped by default. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+}
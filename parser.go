@@ -2,215 +2,1795 @@ package npq
 
 import (
 	"bytes"
+	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"net/url"
 	"reflect"
+	"sort"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"unicode"
 	"unicode/utf8"
 )
 
 // Parser ...
+//
+// A Parser is not safe for concurrent use. To bind values from several
+// goroutines, parse the query once and give each goroutine its own Clone.
 type Parser interface {
 	GetParsedQuery() string
+	GetOriginalQuery() string
 	GetParsedParameters() []interface{}
+	GetParsedParametersForNames(parameterNames ...string) []interface{}
+	GetParameterMap() map[string]interface{}
+	GetNamedArgs() []sql.NamedArg
+	Build() (string, []interface{})
+	SQL() string
+	Args() []interface{}
+	DebugQuery() string
 	SetValue(parameterName string, parameterValue interface{})
+	SetValueStrict(parameterName string, parameterValue interface{}) error
+	SetArrayValue(parameterName string, parameterValue interface{})
+	SetPositional(values ...interface{}) error
+	SetValueAt(position int, parameterValue interface{}) error
+	SetValueAtOccurrence(parameterName string, occurrence int, parameterValue interface{}) error
+	With(parameterName string, parameterValue interface{}) Parser
+	WithValues(parameters map[string]interface{}) Parser
 	SetValuesFromMap(parameters map[string]interface{})
+	SetValuesFromMaps(parameters ...map[string]interface{})
+	SetValuesFromMapReport(parameters map[string]interface{}) []string
+	SetValuesFromMapStrict(parameters map[string]interface{}) error
+	SetValuesFromJSON(data []byte) error
+	SetValuesFromNamedArgs(parameters ...sql.NamedArg)
+	SetValuesFromURLValues(parameters url.Values, sliceNames ...string)
 	SetValuesFromStruct(parameters interface{}) error
+	SetValuesFromStructWithTag(parameters interface{}, tagName string) error
+	ExpandRows(rows interface{}) error
+	Validate() error
+	ParameterNames() []string
+	ParameterCount(parameterName string) int
+	HasParameter(parameterName string) bool
+	NumParameters() int
+	Positions(parameterName string) []int
+	ParameterOffsets() map[string][]int
+	QuotedParameterLikeTokens() []string
+	GetDialect() Dialect
+	ResetValues()
+	Reset()
+	SetDefault(parameterName string, parameterValue interface{})
+	Clone() Parser
+	Reparse(queryText string)
 }
 
 // parser handles the translation of named parameters to positional parameters, for SQL statements.
+//
+// Everything produced by setQuery is never modified afterwards, and is shared between a parser
+// and its clones. Only the values bound to the parameters belong to a single parser.
 type parser struct {
 
-	// A map of parameter names as keys, with value as a slice of positional indices which match
-	// that parameter.
-	positions map[string][]int
+	// The parameter names, in the order they first appear in the query, each with a slice of
+	// positional indices which match that parameter.
+	positions positionStore
 
 	// Contains all positional parameters, in order, ready to be used in the positional query.
 	parameters []interface{}
 
+	// Contains, for every positional parameter, whether a value has been assigned to it.
+	assigned []bool
+
+	// Contains, for every positional parameter, the name of the parameter it was parsed from.
+	positionNames []string
+
+	// Contains, for every positional parameter, the elements of a slice value which
+	// must be expanded into one placeholder each. Nil for values which are not expanded.
+	expansions [][]interface{}
+
+	// The literal query text surrounding the parameter occurrences; the revised query
+	// is every segment joined by the placeholder of the occurrence between them.
+	segments []string
+
+	// Contains, for every occurrence of a parameter in the query, the index of the
+	// positional parameter it refers to.
+	occurrences []int
+
+	// Contains, for every occurrence of a parameter in the query, the byte offset of its
+	// prefix in the original query.
+	offsets []int
+
+	// The optional clauses of the query, in the order they appear.
+	clauses []optionalClause
+
+	// Contains, for every statement after the first, the index of its first positional parameter.
+	statements []int
+
+	// The distinct tokens inside string literals which look like named parameters, e.g. ":name"
+	// in 'hello :name', and so are not parsed as parameters.
+	quotedTokens []string
+
+	// Whether repeated occurrences of a parameter share a single positional parameter.
+	deduplicate bool
+
 	// The query containing named parameters, as passed in by Newparser
 	originalQuery string
 
-	// The query containing positional parameters, as generated by setQuery
+	// The query containing positional parameters, rendered from the segments when it is first
+	// requested, and kept for as long as no parameter is expanded.
 	revisedQuery string
+
+	// Whether revisedQuery has been rendered for the current query.
+	rendered bool
+
+	// The dialect whose placeholder syntax is used in the revised query.
+	dialect Dialect
+
+	// The number of placeholders preceding the query, e.g. in an enclosing query composed of
+	// several fragments, by which the placeholders of its first statement are offset.
+	placeholderOffset int
+
+	// The function producing the placeholder text for a 1-based index, overriding the driver syntax.
+	placeholderFormat func(index int) string
+
+	// The character which starts a named parameter in the original query.
+	prefix rune
+
+	// Whether parameter names are matched regardless of case.
+	caseInsensitive bool
+
+	// Whether SetValuesFromStruct rejects structs with several fields binding the same name.
+	checkDuplicateFields bool
+
+	// Whether SetValuesFromStruct names fields without a parameter name tag by their json tag.
+	jsonTagFallback bool
+
+	// Whether "?" placeholders in the original query are kept as anonymous positional parameters.
+	positionalPassthrough bool
+
+	// The function approving every parameter name when parsing strictly, or nil to accept any name.
+	nameValidator func(parameterName string) error
+
+	// Whether a prefix followed by a digit is kept as an existing numbered placeholder, not a name.
+	numberedPassthrough bool
+
+	// The highest number of the existing placeholders kept by numberedPassthrough, after which
+	// the named parameters are numbered.
+	existingPlaceholders int
+
+	// Whether quotes are ordinary characters, so that parameters inside them are still parsed.
+	ignoreQuotes bool
+
+	// Whether the revised query was written to a stream by ParseStream, so that it is not kept,
+	// and slice values are never expanded.
+	streamed bool
+
+	// Whether square brackets delimit optional clauses, which are omitted if their parameters are unset.
+	optionalClauses bool
+
+	// Whether placeholders are numbered from 1 again in every statement of the query.
+	statementNumbering bool
+
+	// Whether values implementing driver.Valuer are replaced by the result of their Value method.
+	resolveValuers bool
+
+	// Whether values of the standard sql.Null* types are replaced by their contained value, or nil.
+	unwrapNulls bool
+
+	// Whether values of named boolean, numeric or string types are bound as their built-in type.
+	underlyingTypes bool
+
+	// Whether empty or whitespace only string values are bound as nil.
+	blankStringsAsNull bool
+
+	// Whether typed nil values, such as a nil []byte, are bound as an untyped nil.
+	normalizeNils bool
+
+	// The default values of parameters, used for positions which are not explicitly set.
+	defaults map[string]interface{}
+
+	// Non-zero if the parsed query is shared with a clone, and so may not be reused by Reparse.
+	// It is set atomically, since a parser may be cloned from several goroutines at once.
+	shared int32
 }
 
 // NewParser creates a new named parameter query using the given
 // queryText as a SQL query which contains named parameters. Named
 // parameters are identified by starting with a ":" e.g., ":name" refers to
-// the parameter "name", and ":foo" refers to the parameter "foo".
+// the parameter "name", and ":foo" refers to the parameter "foo". A name may
+// also start with a digit, so that ":1" refers to the parameter "1", which is
+// set with SetValue("1", value); it is not treated as an existing positional
+// placeholder.
+//
+// A name may also be enclosed in braces, e.g. ":{id}s", to end it explicitly
+// where it is directly followed by characters which could be part of it; this
+// refers to the parameter "id", followed by the literal text "s".
+// Since DialectNamed writes names without braces, such a name cannot be used
+// with it, and is reported by the strict constructors.
 //
 // Except for their names, named parameters follow all the same rules as
 // positional parameters; they cannot be inside quoted strings, and cannot
 // inject statements into a query. They can only be used to insert values.
+//
+// The revised query uses Postgres style placeholders; use NewParserForDriver
+// to target a different driver.
 func NewParser(queryText string) Parser {
+	return NewParserForDriver(queryText, DialectPostgres)
+}
+
+// NewParserForDriver creates a new named parameter query in the same way as
+// NewParser, but emits positional placeholders in the syntax of the given
+// [driver], e.g. "?" for DialectMySQL instead of "$1".
+func NewParserForDriver(queryText string, driver Driver) Parser {
+
+	p := newParser(driver)
+	p.setQuery(queryText)
+
+	return p
+}
+
+// NewParserWithOffset creates a new named parameter query in the same way as
+// NewParser, but numbers its placeholders after the given [startIndex], so that
+// the first one is "$startIndex+1", e.g. "$4" for a [startIndex] of 3. This allows
+// a larger query to be composed from several parsed fragments, each numbered
+// where the previous one left off; Positions reflects the offset as well. A
+// negative [startIndex] is treated as 0.
+func NewParserWithOffset(queryText string, startIndex int) Parser {
+
+	p := newParser(DialectPostgres)
+
+	if startIndex > 0 {
+		p.placeholderOffset = startIndex
+	}
 
-	// TODO: I don't like using a map for such a small amount of elements.
-	// If p becomes a bottleneck for anyone, the first thing to do would
-	// be to make a slice and search routine for parameter positions.
-	p := &parser{}
-	p.positions = make(map[string][]int, 8)
 	p.setQuery(queryText)
 
 	return p
 }
 
+// NewParserWithMap creates a new named parameter query in the same way as
+// NewParser, and sets its values from the given [parameters] map, as if by
+// calling SetValuesFromMap.
+func NewParserWithMap(queryText string, parameters map[string]interface{}) Parser {
+
+	p := NewParser(queryText)
+	p.SetValuesFromMap(parameters)
+
+	return p
+}
+
+// ParseNamed creates a new named parameter query in the same way as NewParser,
+// and also returns its distinct parameter names in the order they first appear
+// in the query, as returned by ParameterNames.
+func ParseNamed(queryText string) (Parser, []string) {
+
+	p := NewParser(queryText)
+
+	return p, p.ParameterNames()
+}
+
+// NewParserStrict creates a new named parameter query in the same way as
+// NewParser, but returns an error if the query is malformed, e.g. if it
+// contains an unterminated quote or comment, or a parameter without a name.
+func NewParserStrict(queryText string) (Parser, error) {
+
+	var err error
+
+	p := newParser(DialectPostgres)
+	err = p.setQuery(queryText)
+
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// MustParse creates a new named parameter query in the same way as
+// NewParserStrict, but panics if the query is malformed. It simplifies safe
+// initialization of package level variables and tests, in the same way as
+// regexp.MustCompile.
+func MustParse(queryText string) Parser {
+
+	p, err := NewParserStrict(queryText)
+
+	if err != nil {
+		panic("npq: MustParse(" + strconv.Quote(queryText) + "): " + err.Error())
+	}
+	return p
+}
+
+// newParser creates an empty parser for the given [dialect], ready for setQuery.
+func newParser(dialect Dialect) *parser {
+
+	p := &parser{}
+	p.dialect = dialect
+	p.prefix = ':'
+
+	return p
+}
+
 // setQuery parses out all named parameters, stores their locations, and
 // builds a "revised" query which uses positional parameters.
-func (p *parser) setQuery(queryText string) {
+//
+// If the query is malformed, the query is still parsed as well as possible,
+// and an error describing the first problem found is returned.
+func (p *parser) setQuery(queryText string) error {
 
 	var revisedBuilder bytes.Buffer
 	var parameterBuilder bytes.Buffer
 	var position []int
 	var character rune
+	var quote rune
+	var next rune
+	var nextWidth int
 	var parameterName string
+	var braced string
 	var width int
 	var positionIndex int
+	var statementStart int
+	var clause optionalClause
+	var clauseStart int
+	var clauseDepth int
+	var start int
+	var end int
+	var tag string
+	var err error
 
 	p.originalQuery = queryText
+	p.existingPlaceholders = 0
 	positionIndex = 0
+	clauseStart = -1
 
 	for i := 0; i < len(queryText); {
 
 		character, width = utf8.DecodeRuneInString(queryText[i:])
 		i += width
 
-		// if it's a colon, do not write to builder, but grab name
-		if character == ':' {
+		// a double colon is a Postgres type cast, not a parameter.
+		if character == ':' && strings.HasPrefix(queryText[i:], ":") {
+			revisedBuilder.WriteString("::")
+			i++
+			continue
+		}
 
-			for {
+		// ":=" is the PL/pgSQL assignment operator, e.g. in "x := 1", not a parameter.
+		if character == ':' && strings.HasPrefix(queryText[i:], "=") {
+			revisedBuilder.WriteString(":=")
+			i++
+			continue
+		}
+
+		// "@@", "@>" and "<@" are the Postgres text search and containment operators, not parameters.
+		if character == '@' && p.prefix == '@' && (strings.HasPrefix(queryText[i:], "@") || strings.HasPrefix(queryText[i:], ">")) {
+			revisedBuilder.WriteString(queryText[i-width : i+1])
+			i++
+			continue
+		}
+
+		if character == '@' && p.prefix == '@' && bytes.HasSuffix(revisedBuilder.Bytes(), []byte("<")) {
+			revisedBuilder.WriteRune(character)
+			continue
+		}
+
+		// a backslash escaped prefix is a literal prefix character, so that "\:foo" becomes ":foo".
+		if character == '\\' && strings.HasPrefix(queryText[i:], string(p.prefix)) {
+			revisedBuilder.WriteString(string(p.prefix))
+			i += utf8.RuneLen(p.prefix)
+			continue
+		}
+
+		// an existing positional placeholder is an anonymous parameter with its own position.
+		if character == '?' && p.positionalPassthrough {
+
+			p.positionNames = append(p.positionNames, "")
+			p.occurrences = append(p.occurrences, positionIndex)
+			p.offsets = append(p.offsets, i-width)
+			positionIndex++
+
+			p.segments = append(p.segments, revisedBuilder.String())
+			revisedBuilder.Reset()
+			continue
+		}
+
+		// if it's the parameter prefix, do not write to builder, but grab name
+		if character == p.prefix {
+
+			start = i - width
+
+			// an existing numbered placeholder such as "$1" is kept as is, if p passes them through,
+			// and the named parameters are numbered after the highest of them.
+			if p.numberedPassthrough {
+
+				next, nextWidth = utf8.DecodeRuneInString(queryText[i:])
+
+				if next >= '0' && next <= '9' {
+
+					for next >= '0' && next <= '9' {
+						i += nextWidth
+						next, nextWidth = utf8.DecodeRuneInString(queryText[i:])
+					}
+
+					if number, numberErr := strconv.Atoi(queryText[start+width : i]); numberErr == nil && number > p.existingPlaceholders {
+						p.existingPlaceholders = number
+					}
+
+					revisedBuilder.WriteString(queryText[start:i])
+					continue
+				}
+			}
+
+			// a name in braces, e.g. ":{id}s", ends at the closing brace instead of the first
+			// character which cannot be part of a name.
+			braced = bracedName(queryText[i:])
+
+			if len(braced) > 0 {
+
+				parameterBuilder.WriteString(braced)
+				i += len(braced) + 2
+
+				// a named dialect writes the name without its braces, which would join it with
+				// the characters following it, e.g. ":{id}s" with "s".
+				next, nextWidth = utf8.DecodeRuneInString(queryText[i:])
+
+				if p.dialect.capabilities().named && err == nil && (isNameCharacter(next) || unicode.IsMark(next) || (next == '.' && isNameCharacter(firstRune(queryText[i+nextWidth:])))) {
+					err = fmt.Errorf("Unable to parse query: parameter %s{%s} at byte %d is followed by name characters, which the named dialect cannot express", string(p.prefix), braced, start)
+				}
+			}
+
+			for len(braced) <= 0 {
 
 				character, width = utf8.DecodeRuneInString(queryText[i:])
-				i += width
 
-				if unicode.IsLetter(character) || unicode.IsDigit(character) || character == '_' {
+				// combining marks may follow the first character, e.g. in a decomposed "naïve".
+				if isNameCharacter(character) || (unicode.IsMark(character) && parameterBuilder.Len() > 0) {
 					parameterBuilder.WriteString(string(character))
-				} else {
-					break
+					i += width
+					continue
 				}
+
+				// a dot separates the parts of a nested name such as ":user.name",
+				// but only if another part follows it.
+				if character == '.' && parameterBuilder.Len() > 0 {
+
+					next, _ = utf8.DecodeRuneInString(queryText[i+width:])
+
+					if isNameCharacter(next) {
+						parameterBuilder.WriteString(string(character))
+						i += width
+						continue
+					}
+				}
+				break
 			}
 
 			// add to positions
-			parameterName = parameterBuilder.String()
-			position = p.positions[parameterName]
-			p.positions[parameterName] = append(position, positionIndex)
-			positionIndex++
+			parameterName = p.normalizeName(parameterBuilder.String())
+
+			// a prefix without a name is not a parameter, and is kept as a literal character.
+			if len(parameterName) <= 0 {
+
+				if err == nil {
+					err = fmt.Errorf("Unable to parse query: parameter without a name at byte %d", i-utf8.RuneLen(p.prefix))
+				}
+
+				revisedBuilder.WriteString(string(p.prefix))
+				continue
+			}
+
+			if p.nameValidator != nil && err == nil {
+				if validationErr := p.nameValidator(parameterName); validationErr != nil {
+					err = fmt.Errorf("Unable to parse query: invalid parameter name %s%s at byte %d: %v", string(p.prefix), parameterName, start, validationErr)
+				}
+			}
+
+			position = p.positions.get(parameterName)
+
+			// a deduplicated parameter reuses the position of its first occurrence in the statement.
+			if !p.deduplicates() || position == nil || position[len(position)-1] < statementStart {
 
-			// TODO: Add support for other drivers
-			// Postgres placeholder syntax
-			revisedBuilder.WriteString("$" + strconv.Itoa(positionIndex))
+				p.positions.add(parameterName, positionIndex)
+				p.positionNames = append(p.positionNames, parameterName)
+				positionIndex++
+
+				position = p.positions.get(parameterName)
+			}
+
+			p.occurrences = append(p.occurrences, position[len(position)-1])
+			p.offsets = append(p.offsets, start)
+
+			p.segments = append(p.segments, revisedBuilder.String())
+			revisedBuilder.Reset()
 			parameterBuilder.Reset()
 
-			if width <= 0 {
-				break
+			// the character which ended the name is left for the next iteration,
+			// so that a cast or quote directly after a parameter is handled normally.
+			continue
+		}
+
+		// a Postgres dollar quoted string, e.g. "$$ ... $$" or "$body$ ... $body$", is copied verbatim.
+		if character == '$' && p.dialect.dollarQuotes() && p.prefix != '$' && !p.ignoreQuotes {
+
+			tag = dollarQuoteTag(queryText[i-width:])
+
+			if len(tag) > 0 {
+
+				start = i - width
+				end = strings.Index(queryText[start+len(tag):], tag)
+
+				if end < 0 {
+
+					if err == nil {
+						err = fmt.Errorf("Unable to parse query: unterminated dollar quote starting at byte %d", start)
+					}
+					end = len(queryText)
+				} else {
+					end += start + 2*len(tag)
+					p.addQuotedTokens(queryText[start:end])
+				}
+
+				revisedBuilder.WriteString(queryText[start:end])
+				i = end
+				continue
+			}
+		}
+
+		// a semicolon outside of quotes and comments ends a statement.
+		if character == ';' && p.statementNumbering {
+			p.statements = append(p.statements, positionIndex)
+			statementStart = positionIndex
+		}
+
+		// square brackets delimit an optional clause, unless they are a subscript, e.g. "col[1]".
+		if p.optionalClauses && (character == '[' || character == ']') {
+
+			if clauseStart < 0 && character == '[' && !isSubscript(&revisedBuilder) {
+
+				clauseStart = i - width
+				clause = optionalClause{first: len(p.segments), start: revisedBuilder.Len()}
+				continue
+			}
+
+			if clauseStart >= 0 && character == ']' && clauseDepth <= 0 {
+
+				clause.last = len(p.segments)
+				clause.end = revisedBuilder.Len()
+				p.clauses = append(p.clauses, clause)
+				clauseStart = -1
+				continue
+			}
+
+			// brackets nested in a clause are part of its text.
+			if clauseStart >= 0 && character == '[' {
+				clauseDepth++
+			} else if clauseStart >= 0 {
+				clauseDepth--
 			}
 		}
 
-		// otherwise write.
-		revisedBuilder.WriteString(string(character))
+		// otherwise write. The original bytes are copied, so that invalid UTF-8 is kept as is.
+		revisedBuilder.WriteString(queryText[i-width : i])
+
+		// if it's a quote or a quoted identifier, continue writing to builder, but do not search for parameters.
+		// A doubled quote character inside the quoted region is an escaped quote, and does not end it,
+		// nor does a backslash escaped quote for drivers which support them.
+		if (character == '\'' || character == '"') && !p.ignoreQuotes {
+
+			quote = character
+			start = i - width
+
+			for {
+
+				character, width = utf8.DecodeRuneInString(queryText[i:])
+				i += width
+
+				if width <= 0 {
+
+					if err == nil {
+						err = fmt.Errorf("Unable to parse query: unterminated quote starting at byte %d", start)
+					}
+					break
+				}
+
+				revisedBuilder.WriteString(queryText[i-width : i])
+
+				// a backslash escapes the following character, including a quote.
+				if character == '\\' && p.dialect.backslashEscapes() {
+
+					next, nextWidth = utf8.DecodeRuneInString(queryText[i:])
+
+					if nextWidth > 0 {
+						revisedBuilder.WriteString(queryText[i : i+nextWidth])
+						i += nextWidth
+					}
+					continue
+				}
+
+				if character == quote {
+
+					next, nextWidth = utf8.DecodeRuneInString(queryText[i:])
+
+					if next != quote {
+
+						if quote == '\'' {
+							p.addQuotedTokens(queryText[start:i])
+						}
+						break
+					}
+
+					revisedBuilder.WriteString(queryText[i : i+nextWidth])
+					i += nextWidth
+				}
+			}
+		}
 
-		// if it's a quote, continue writing to builder, but do not search for parameters.
-		if character == '\'' {
+		// if it's a line comment, copy the rest of the line without searching for parameters.
+		if character == '-' && strings.HasPrefix(queryText[i:], "-") {
 
 			for {
 
 				character, width = utf8.DecodeRuneInString(queryText[i:])
 				i += width
-				revisedBuilder.WriteString(string(character))
 
-				if character == '\'' {
+				if width <= 0 {
+					break
+				}
+
+				revisedBuilder.WriteString(queryText[i-width : i])
+
+				// a carriage return also ends the line, as in a lone "\r" or a "\r\n" line ending.
+				if character == '\n' || character == '\r' {
 					break
 				}
 			}
 		}
+
+		// if it's a block comment, copy everything up to and including the closing "*/"
+		// without searching for parameters. An unterminated comment runs to the end of the query.
+		if character == '/' && strings.HasPrefix(queryText[i:], "*") {
+
+			end := strings.Index(queryText[i+1:], "*/")
+
+			if end < 0 {
+
+				if err == nil {
+					err = fmt.Errorf("Unable to parse query: unterminated comment starting at byte %d", i-1)
+				}
+				end = len(queryText)
+			} else {
+				end += i + 3
+			}
+
+			revisedBuilder.WriteString(queryText[i:end])
+			i = end
+		}
 	}
 
-	p.revisedQuery = revisedBuilder.String()
-	p.parameters = make([]interface{}, positionIndex)
-}
+	// an unterminated optional clause runs to the end of the query.
+	if clauseStart >= 0 {
 
-// GetParsedQuery returns a version of the original query text
-// whose named parameters have been replaced by positional parameters.
-func (p *parser) GetParsedQuery() string {
-	return p.revisedQuery
+		if err == nil {
+			err = fmt.Errorf("Unable to parse query: unterminated optional clause starting at byte %d", clauseStart)
+		}
+
+		clause.last = len(p.segments)
+		clause.end = revisedBuilder.Len()
+		p.clauses = append(p.clauses, clause)
+	}
+
+	p.segments = append(p.segments, revisedBuilder.String())
+
+	// the values of a previous query are never shared, so their memory may be reused.
+	if cap(p.parameters) >= positionIndex {
+
+		p.parameters = p.parameters[:positionIndex]
+		p.assigned = p.assigned[:positionIndex]
+		p.expansions = p.expansions[:positionIndex]
+
+		for position := range p.parameters {
+			p.parameters[position] = nil
+			p.assigned[position] = false
+			p.expansions[position] = nil
+		}
+	} else {
+		p.parameters = make([]interface{}, positionIndex)
+		p.assigned = make([]bool, positionIndex)
+		p.expansions = make([][]interface{}, positionIndex)
+	}
+	p.revisedQuery = ""
+	p.rendered = false
+
+	return err
 }
 
-// GetParsedParameters returns an array of parameter objects that match the
-// positional parameter list from GetParsedQuery
-func (p *parser) GetParsedParameters() []interface{} {
-	return p.parameters
+// deduplicates returns true if repeated occurrences of a parameter in p query share
+// a single positional parameter, i.e. if p was created with WithDeduplication and
+// the placeholders of its dialect may be reused.
+func (p *parser) deduplicates() bool {
+	return p.deduplicate && p.dialect.reusesPlaceholders()
 }
 
-// SetValue sets the value of the given [parameterName] to the given [parameterValue].
-// If the parsed query does not have a placeholder for the given [parameterName],
-// p method does nothing.
-func (p *parser) SetValue(parameterName string, parameterValue interface{}) {
+// normalizeName returns the given [parameterName] as it is stored in the positions
+// store, i.e. lower cased if p matches parameter names regardless of case.
+func (p *parser) normalizeName(parameterName string) string {
 
-	for _, position := range p.positions[parameterName] {
-		p.parameters[position] = parameterValue
+	if p.caseInsensitive {
+		return strings.ToLower(parameterName)
 	}
+	return parameterName
 }
 
-// SetValuesFromMap uses every key/value pair in the given [parameters] as a
-// parameter replacement for p query. This is equivalent to calling SetValue
-// for every key/value pair in the given [parameters] map.  If there are any
-// keys/values present in the map that aren't part of the query, they are
-// ignored.
-func (p *parser) SetValuesFromMap(parameters map[string]interface{}) {
+// isNameCharacter returns true if the given [character] may be part of a parameter name.
+func isNameCharacter(character rune) bool {
+	return unicode.IsLetter(character) || unicode.IsDigit(character) || character == '_'
+}
 
-	for name, value := range parameters {
-		p.SetValue(name, value)
+// bracedName returns the parameter name enclosed in the braces at the start of the
+// given [text], e.g. "id" for "{id}s", or an empty string if text does not start
+// with a brace, or the braces do not enclose a valid name. Within the braces, a
+// name follows the same rules as any other, including dots between its parts.
+func bracedName(text string) string {
+
+	var character rune
+	var previous rune
+	var width int
+
+	if !strings.HasPrefix(text, "{") {
+		return ""
+	}
+
+	for i := 1; i < len(text); i += width {
+
+		character, width = utf8.DecodeRuneInString(text[i:])
+
+		// the name may not be empty, nor end with a dot.
+		if character == '}' && i > 1 && previous != '.' {
+			return text[1:i]
+		}
+
+		if !isNameCharacter(character) && !(unicode.IsMark(character) && i > 1) && !(character == '.' && isNameCharacter(previous)) {
+			return ""
+		}
+		previous = character
 	}
+	return ""
 }
 
-// SetValuesFromStruct uses reflection to find every public field of the given struct [parameters]
-// and set their key/value as named parameters in p query.
-// If the given [parameters] is not a struct, p will return an error.
-//
-// If you do not wish for a field in the struct to be added by its literal name,
-// The struct may optionally specify the sqlParameterName as a tag on the field.
-// e.g., a struct field may say something like:
-//
-// 	type Test struct {
-// 		Foo string `sqlParameterName:"foobar"`
-// 	}
-func (p *parser) SetValuesFromStruct(parameters interface{}) error {
+// firstRune returns the first rune of the given [text], or utf8.RuneError if it is empty.
+func firstRune(text string) rune {
+
+	var character rune
+
+	character, _ = utf8.DecodeRuneInString(text)
+	return character
+}
+
+// dollarQuoteTag returns the opening tag of the Postgres dollar quoted string at
+// the start of the given [text], e.g. "$$" or "$body$", or an empty string if
+// text does not start with one. A tag follows the rules of an unquoted
+// identifier, so that a positional parameter such as "$1" is not a tag.
+func dollarQuoteTag(text string) string {
 
-	var fieldValues reflect.Value
-	var fieldValue reflect.Value
-	var parameterType reflect.Type
-	var parameterField reflect.StructField
-	var queryTag string
-	var visibilityCharacter rune
+	var character rune
+	var width int
+
+	for i := 1; i < len(text); i += width {
+
+		character, width = utf8.DecodeRuneInString(text[i:])
 
-	fieldValues = reflect.ValueOf(parameters)
+		if character == '$' {
+			return text[:i+width]
+		}
 
-	if fieldValues.Kind() != reflect.Struct {
-		return errors.New("Unable to add query values from parameter: parameter is not a struct")
+		if !isNameCharacter(character) || (i == 1 && unicode.IsDigit(character)) {
+			return ""
+		}
 	}
+	return ""
+}
+
+// addQuotedTokens records every token of the given quoted string [literal] which
+// would be a named parameter if it were not quoted. A prefix directly
+// after a name character or a backslash, e.g. in '12:30' or '\:foo', is not a token.
+func (p *parser) addQuotedTokens(literal string) {
 
-	parameterType = fieldValues.Type()
+	var character rune
+	var previous rune
+	var width int
+	var end int
+	var token string
+	var exists bool
 
-	for i := 0; i < fieldValues.NumField(); i++ {
+	for i := 0; i < len(literal); {
 
-		fieldValue = fieldValues.Field(i)
-		parameterField = parameterType.Field(i)
+		character, width = utf8.DecodeRuneInString(literal[i:])
+		i += width
 
-		// public field?
-		visibilityCharacter, _ = utf8.DecodeRuneInString(parameterField.Name[0:])
+		if character != p.prefix || isNameCharacter(previous) || previous == '\\' || previous == p.prefix {
+			previous = character
+			continue
+		}
 
-		if fieldValue.CanSet() || unicode.IsUpper(visibilityCharacter) {
+		for end = i; end < len(literal); end += width {
 
-			// check to see if p has a tag indicating a different query name
-			queryTag = parameterField.Tag.Get("sqlParameterName")
+			character, width = utf8.DecodeRuneInString(literal[end:])
 
-			// otherwise just add the struct's name.
-			if len(queryTag) <= 0 {
-				queryTag = parameterField.Name
+			if !isNameCharacter(character) && !(unicode.IsMark(character) && end > i) {
+				break
 			}
+		}
 
-			p.SetValue(queryTag, fieldValue.Interface())
+		if end <= i {
+			previous = p.prefix
+			continue
 		}
-	}
-	return nil
+
+		previous, _ = utf8.DecodeLastRuneInString(literal[:end])
+
+		token = literal[i-utf8.RuneLen(p.prefix) : end]
+		exists = false
+
+		for _, quotedToken := range p.quotedTokens {
+			if quotedToken == token {
+				exists = true
+				break
+			}
+		}
+
+		if !exists {
+			p.quotedTokens = append(p.quotedTokens, token)
+		}
+		i = end
+	}
+}
+
+// GetParsedQuery returns a version of the original query text
+// whose named parameters have been replaced by positional parameters.
+//
+// Parameters bound to a slice value are expanded into one placeholder per element.
+// The placeholders are only numbered when the query is requested, so that every
+// placeholder after an expanded one is renumbered to follow it.
+func (p *parser) GetParsedQuery() string {
+
+	if p.isRewritten() {
+		return p.render()
+	}
+
+	if !p.rendered {
+		p.revisedQuery = p.render()
+		p.rendered = true
+	}
+	return p.revisedQuery
+}
+
+// GetOriginalQuery returns the query text containing named parameters, exactly
+// as it was given to p, e.g. to correlate a logged revised query with its source.
+// It is empty for a query parsed by ParseStream.
+func (p *parser) GetOriginalQuery() string {
+	return p.originalQuery
+}
+
+// GetParsedParameters returns an array of parameter objects that match the
+// positional parameter list from GetParsedQuery
+func (p *parser) GetParsedParameters() []interface{} {
+
+	var parameters []interface{}
+	var omitted []bool
+
+	if !p.isRewritten() {
+		return p.parameters
+	}
+
+	parameters = make([]interface{}, 0, len(p.parameters))
+	omitted = p.omittedPositions(p.omittedOccurrences())
+
+	for position, parameter := range p.parameters {
+
+		if omitted != nil && omitted[position] {
+			continue
+		}
+
+		if p.expansions[position] != nil {
+			parameters = append(parameters, p.expansions[position]...)
+		} else {
+			parameters = append(parameters, parameter)
+		}
+	}
+	return parameters
+}
+
+// SQL returns the revised query of p, as returned by GetParsedQuery, under a name
+// which reads naturally at call sites, e.g.
+//
+// 	db.QueryContext(ctx, prsr.SQL(), prsr.Args()...)
+func (p *parser) SQL() string {
+	return p.GetParsedQuery()
+}
+
+// Args returns the positional parameters of p, as returned by GetParsedParameters.
+func (p *parser) Args() []interface{} {
+	return p.GetParsedParameters()
+}
+
+// GetParsedParametersForNames returns the positional parameters of p query, as
+// returned by GetParsedParameters, which belong to any of the given
+// [parameterNames], in their positional order, e.g. to reuse a subset of the
+// bound values in another query. Names which are not part of p query are ignored.
+func (p *parser) GetParsedParametersForNames(parameterNames ...string) []interface{} {
+
+	var parameters []interface{}
+	var selected map[string]bool
+	var omitted []bool
+
+	selected = make(map[string]bool, len(parameterNames))
+	omitted = p.omittedPositions(p.omittedOccurrences())
+
+	for _, name := range parameterNames {
+		selected[p.normalizeName(name)] = true
+	}
+
+	for position, parameter := range p.parameters {
+
+		if !selected[p.positionNames[position]] || (omitted != nil && omitted[position]) {
+			continue
+		}
+
+		if p.expansions[position] != nil {
+			parameters = append(parameters, p.expansions[position]...)
+		} else {
+			parameters = append(parameters, parameter)
+		}
+	}
+	return parameters
+}
+
+// Build returns both the revised query and its positional parameters, as
+// returned by GetParsedQuery and GetParsedParameters.
+func (p *parser) Build() (string, []interface{}) {
+	return p.GetParsedQuery(), p.GetParsedParameters()
+}
+
+// GetParameterMap returns the value bound to every distinct parameter of p query,
+// keyed by its name, e.g. for logging the values a query was executed with. A
+// parameter which has not been assigned a value maps to nil. Anonymous positional
+// parameters are keyed by their 1-based position, as "?N".
+func (p *parser) GetParameterMap() map[string]interface{} {
+
+	var parameters map[string]interface{}
+	var name string
+
+	parameters = make(map[string]interface{}, len(p.parameters))
+
+	for position, parameter := range p.parameters {
+
+		name = p.positionNames[position]
+
+		if len(name) <= 0 {
+			name = "?" + strconv.Itoa(position+1)
+		} else if _, exists := parameters[name]; exists {
+			continue
+		}
+
+		parameters[name] = parameter
+	}
+
+	return parameters
+}
+
+// GetNamedArgs returns the value bound to every distinct named parameter of p
+// query as a sql.NamedArg, in the order the parameters first appear, for drivers
+// which bind parameters by name, e.g. together with the query of DialectNamed.
+// Slice values are returned as they were bound. Anonymous positional parameters,
+// and the parameters of omitted optional clauses, are not returned.
+func (p *parser) GetNamedArgs() []sql.NamedArg {
+
+	var namedArgs []sql.NamedArg
+	var omitted []bool
+
+	omitted = p.omittedPositions(p.omittedOccurrences())
+	namedArgs = make([]sql.NamedArg, 0, p.positions.len())
+
+	for i, name := range p.positions.names {
+
+		for _, position := range p.positions.indices[i] {
+
+			if omitted != nil && omitted[position] {
+				continue
+			}
+
+			namedArgs = append(namedArgs, sql.Named(name, p.parameters[position]))
+			break
+		}
+	}
+	return namedArgs
+}
+
+// SetValue sets the value of the given [parameterName] to the given [parameterValue].
+// If the parsed query does not have a placeholder for the given [parameterName],
+// p method does nothing.
+//
+// If [parameterValue] is a slice or array (other than []byte), each of its elements
+// is bound to its own placeholder, so that ":ids" in "IN (:ids)" expands to "$1, $2, $3".
+// An empty slice is bound as a single NULL.
+func (p *parser) SetValue(parameterName string, parameterValue interface{}) {
+
+	for _, position := range p.positions.get(p.normalizeName(parameterName)) {
+		p.setPosition(position, parameterValue)
+	}
+}
+
+// SetArrayValue sets the value of the given [parameterName] in the same way as
+// SetValue, but never expands a slice value into multiple placeholders, so that
+// it is bound as a single array parameter, e.g. for "WHERE id = ANY(:ids)".
+// Depending on the driver, the value may need to be wrapped, e.g. in pq.Array.
+func (p *parser) SetArrayValue(parameterName string, parameterValue interface{}) {
+
+	for _, position := range p.positions.get(p.normalizeName(parameterName)) {
+		p.setPosition(position, parameterValue)
+		p.expansions[position] = nil
+	}
+}
+
+// setPosition sets the value of the positional parameter at the given 0-based
+// [position] to the given [parameterValue].
+func (p *parser) setPosition(position int, parameterValue interface{}) {
+
+	parameterValue = p.transform(parameterValue)
+
+	p.parameters[position] = parameterValue
+	p.assigned[position] = true
+	p.expansions[position] = p.expansion(parameterValue)
+}
+
+// transform returns the given [value] as it is bound to a parameter of p, after
+// applying the conversions enabled by the options of p, e.g. WithUnderlyingTypes.
+// The elements of an expanded slice value are transformed in the same way.
+func (p *parser) transform(value interface{}) interface{} {
+
+	if p.unwrapNulls {
+		value = nullValue(value)
+	}
+
+	if p.resolveValuers {
+		value = resolveValuer(value)
+	}
+
+	if p.underlyingTypes {
+		value = underlyingValue(value)
+	}
+
+	if p.blankStringsAsNull && isBlankString(value) {
+		value = nil
+	}
+
+	if p.normalizeNils && isNilValue(value) {
+		value = nil
+	}
+	return value
+}
+
+// SetValueAt sets the value of the positional parameter at the given 0-based
+// [position] to the given [parameterValue], regardless of its name, so that
+// position 0 is the parameter of "$1" in a query without slice values. If the
+// position is out of range, SetValueAt returns an error and sets nothing.
+func (p *parser) SetValueAt(position int, parameterValue interface{}) error {
+
+	if position < 0 || position >= len(p.parameters) {
+		return fmt.Errorf("Unable to set value: position %d is out of range, query has %d positional parameters", position, len(p.parameters))
+	}
+
+	p.setPosition(position, parameterValue)
+	return nil
+}
+
+// SetValueAtOccurrence sets the value of only the given 0-based [occurrence] of
+// the given [parameterName] in p query to the given [parameterValue], leaving its
+// other occurrences as they are, so that ":x = :x" can be bound to two different
+// values. The occurrences are counted as the positions returned by Positions, so
+// that with WithDeduplication, every occurrence in a statement is the same one.
+// If the name is not part of p query or the occurrence is out of range,
+// SetValueAtOccurrence returns an error and sets nothing.
+func (p *parser) SetValueAtOccurrence(parameterName string, occurrence int, parameterValue interface{}) error {
+
+	var positions []int
+
+	positions = p.positions.get(p.normalizeName(parameterName))
+
+	if len(positions) <= 0 {
+		return fmt.Errorf("Unable to set value: query has no parameter %s%s", string(p.prefix), parameterName)
+	}
+
+	if occurrence < 0 || occurrence >= len(positions) {
+		return fmt.Errorf("Unable to set value: occurrence %d of parameter %s%s is out of range, it occurs %d times", occurrence, string(p.prefix), parameterName, len(positions))
+	}
+
+	p.setPosition(positions[occurrence], parameterValue)
+	return nil
+}
+
+// isBlankString returns true if the given [value] is of a string kind, including
+// named string types, and is empty or contains only whitespace.
+func isBlankString(value interface{}) bool {
+
+	var reflectValue reflect.Value
+
+	if value == nil {
+		return false
+	}
+
+	reflectValue = reflect.ValueOf(value)
+
+	return reflectValue.Kind() == reflect.String && len(strings.TrimSpace(reflectValue.String())) <= 0
+}
+
+// isNilValue returns true if the given [value] is a typed nil, i.e. a nil
+// pointer, slice, map, channel, function or interface stored in an interface{}.
+func isNilValue(value interface{}) bool {
+
+	var reflectValue reflect.Value
+
+	if value == nil {
+		return false
+	}
+
+	reflectValue = reflect.ValueOf(value)
+
+	switch reflectValue.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Chan, reflect.Func, reflect.Interface:
+		return reflectValue.IsNil()
+	}
+	return false
+}
+
+// SetPositional sets the values of the positional parameters of p query, in
+// order, to the given [values], regardless of their names. If fewer values are
+// given than p query has positional parameters, the remaining ones are left
+// unchanged. If more values are given, SetPositional returns an error and sets
+// nothing.
+func (p *parser) SetPositional(values ...interface{}) error {
+
+	if len(values) > len(p.parameters) {
+		return fmt.Errorf("Unable to set positional values: %d values given, but query has %d positional parameters", len(values), len(p.parameters))
+	}
+
+	for position, value := range values {
+		p.setPosition(position, value)
+	}
+	return nil
+}
+
+// SetValueStrict sets the value of the given [parameterName] in the same way as
+// SetValue, but returns an error if the parsed query does not have a placeholder
+// for the given [parameterName], e.g. because of a typo in the name.
+func (p *parser) SetValueStrict(parameterName string, parameterValue interface{}) error {
+
+	if !p.positions.has(p.normalizeName(parameterName)) {
+		return fmt.Errorf("Unable to set value: query has no parameter %s%s", string(p.prefix), parameterName)
+	}
+
+	p.SetValue(parameterName, parameterValue)
+	return nil
+}
+
+// With sets the value of the given [parameterName] in the same way as SetValue,
+// and returns p so that calls can be chained, e.g.
+//
+// 	p.With("a", 1).With("b", 2)
+func (p *parser) With(parameterName string, parameterValue interface{}) Parser {
+
+	p.SetValue(parameterName, parameterValue)
+	return p
+}
+
+// WithValues sets the values of p query from the given [parameters] map in the
+// same way as SetValuesFromMap, and returns p so that calls can be chained, e.g.
+//
+// 	query, args := NewParser(queryText).WithValues(parameters).Build()
+func (p *parser) WithValues(parameters map[string]interface{}) Parser {
+
+	p.SetValuesFromMap(parameters)
+	return p
+}
+
+// SetValuesFromMap uses every key/value pair in the given [parameters] as a
+// parameter replacement for p query. This is equivalent to calling SetValue
+// for every key/value pair in the given [parameters] map.  If there are any
+// keys/values present in the map that aren't part of the query, they are
+// ignored.
+//
+// A value which is itself a map[string]interface{} also binds dotted parameter
+// names, so that ":user.name" is bound to parameters["user"]["name"]. If any
+// part of a dotted name is missing, the parameter is left unset.
+func (p *parser) SetValuesFromMap(parameters map[string]interface{}) {
+	p.setValuesFromNestedMap(parameters, "", nil, true)
+}
+
+// SetValuesFromMapReport sets the values of p query from the given [parameters]
+// map in the same way as SetValuesFromMap, and returns the keys of the map which
+// did not match any parameter, sorted, e.g. to find a misspelled "user_id" for a
+// query using ":userId". Unmatched keys of nested maps are returned as dotted
+// names, e.g. "user.nmae". If every key matched, nil is returned.
+func (p *parser) SetValuesFromMapReport(parameters map[string]interface{}) []string {
+
+	var unmatched []string
+
+	unmatched = p.setValuesFromNestedMap(parameters, "", nil, true)
+	sort.Strings(unmatched)
+
+	return unmatched
+}
+
+// SetValuesFromMapStrict sets the values of p query from the given [parameters]
+// map in the same way as SetValuesFromMap, but returns an error naming every key
+// of the map which does not match any parameter, as reported by
+// SetValuesFromMapReport, and sets nothing, so that callers must pass exactly the
+// parameters of the query. Parameters of the query missing from the map are not
+// an error; use Validate to find them.
+func (p *parser) SetValuesFromMapStrict(parameters map[string]interface{}) error {
+
+	var unmatched []string
+
+	unmatched = p.setValuesFromNestedMap(parameters, "", nil, false)
+
+	if len(unmatched) > 0 {
+
+		sort.Strings(unmatched)
+
+		for i := range unmatched {
+			unmatched[i] = string(p.prefix) + unmatched[i]
+		}
+
+		if len(unmatched) == 1 {
+			return fmt.Errorf("Unable to set values: query has no parameter %s", unmatched[0])
+		}
+		return fmt.Errorf("Unable to set values: query has no parameters %s", strings.Join(unmatched, ", "))
+	}
+
+	p.setValuesFromNestedMap(parameters, "", nil, true)
+	return nil
+}
+
+// setValuesFromNestedMap binds every key/value pair of the given [parameters],
+// with every key prefixed by the given [namePrefix], recursing into nested maps
+// if p query has a nested parameter name starting with their key. The prefixed
+// keys which match no parameter are appended to the given [unmatched] keys,
+// which are returned. If [bind] is false, nothing is bound, and only the
+// unmatched keys are collected.
+func (p *parser) setValuesFromNestedMap(parameters map[string]interface{}, namePrefix string, unmatched []string, bind bool) []string {
+
+	var nested bool
+
+	for name, value := range parameters {
+
+		name = namePrefix + name
+
+		if bind {
+			p.SetValue(name, value)
+		}
+
+		nestedMap, isMap := value.(map[string]interface{})
+		nested = isMap && p.hasNestedNames(name)
+
+		if nested {
+			unmatched = p.setValuesFromNestedMap(nestedMap, name+".", unmatched, bind)
+		}
+
+		if !nested && !p.positions.has(p.normalizeName(name)) {
+			unmatched = append(unmatched, name)
+		}
+	}
+
+	return unmatched
+}
+
+// SetValuesFromJSON sets the values of p query from the given [data], which must
+// be a JSON object, in the same way as SetValuesFromMap with the object decoded
+// into a map[string]interface{}. Nested objects bind dotted parameter names, and
+// arrays are expanded like any other slice.
+//
+// Values are decoded by encoding/json, so every JSON number is bound as a
+// float64, e.g. an id of 5 is bound as 5.0. If data is not a JSON object,
+// SetValuesFromJSON returns an error and sets nothing.
+func (p *parser) SetValuesFromJSON(data []byte) error {
+
+	var parameters map[string]interface{}
+	var err error
+
+	err = json.Unmarshal(data, &parameters)
+
+	if err != nil {
+		return fmt.Errorf("Unable to add query values from JSON: %v", err)
+	}
+
+	// a JSON null decodes without an error, but leaves the map nil.
+	if parameters == nil {
+		return errors.New("Unable to add query values from JSON: data is not an object")
+	}
+
+	p.SetValuesFromMap(parameters)
+	return nil
+}
+
+// SetValuesFromMaps calls SetValuesFromMap for each of the given [parameters]
+// maps in order, so that a value in a later map overrides the value for the
+// same parameter in an earlier one.
+func (p *parser) SetValuesFromMaps(parameters ...map[string]interface{}) {
+
+	for _, parameterMap := range parameters {
+		p.SetValuesFromMap(parameterMap)
+	}
+}
+
+// SetValuesFromNamedArgs sets the value of every given named argument, as
+// created by sql.Named, in the same way as calling SetValue with its Name and
+// Value.
+func (p *parser) SetValuesFromNamedArgs(parameters ...sql.NamedArg) {
+
+	for _, parameter := range parameters {
+		p.SetValue(parameter.Name, parameter.Value)
+	}
+}
+
+// SetValuesFromURLValues sets the value of every key of the given [parameters],
+// e.g. as parsed from a query string, in the same way as SetValuesFromMap.
+//
+// A key given several times, e.g. "?id=1&id=2", is bound to its first value only,
+// as a string, unless its name is one of the given [sliceNames], in which case it
+// is bound to the whole []string of its values, and expanded like any other slice.
+// Slice names are matched in the same way as parameter names, i.e. regardless of
+// case if p was created with WithCaseInsensitiveNames. Keys without any value are
+// ignored.
+func (p *parser) SetValuesFromURLValues(parameters url.Values, sliceNames ...string) {
+
+	var sliceName bool
+
+	for name, values := range parameters {
+
+		if len(values) == 0 {
+			continue
+		}
+
+		sliceName = false
+
+		for _, candidate := range sliceNames {
+			if p.normalizeName(candidate) == p.normalizeName(name) {
+				sliceName = true
+				break
+			}
+		}
+
+		if sliceName {
+			p.SetValue(name, values)
+		} else {
+			p.SetValue(name, values[0])
+		}
+	}
+}
+
+// Validate returns an error naming every parameter of p query which has never
+// been assigned a value. A parameter deliberately set to nil counts as assigned.
+//
+// Otherwise, an error naming every parameter bound to a slice value which is
+// expanded outside of a parenthesized list is returned, e.g. for "col = :ids",
+// since the expanded placeholders would produce invalid SQL; such a value should
+// be used as in "col IN (:ids)", or bound with SetArrayValue instead.
+// If every parameter has been assigned, and can be expanded, Validate returns nil.
+func (p *parser) Validate() error {
+
+	var missing []string
+	var misplaced []string
+	var reported map[string]bool
+	var omittedOccurrences []bool
+	var omitted []bool
+	var name string
+
+	reported = make(map[string]bool, p.positions.len())
+	omittedOccurrences = p.omittedOccurrences()
+	omitted = p.omittedPositions(omittedOccurrences)
+
+	for position, assigned := range p.assigned {
+
+		name = p.positionNames[position]
+
+		if assigned || reported[name] {
+			continue
+		}
+
+		// the parameters of an omitted optional clause are not needed.
+		if omitted != nil && omitted[position] {
+			continue
+		}
+
+		if _, hasDefault := p.defaults[name]; hasDefault {
+			continue
+		}
+
+		// anonymous parameters are reported by their position instead.
+		if len(name) <= 0 {
+			missing = append(missing, "?"+strconv.Itoa(position+1))
+			continue
+		}
+
+		reported[name] = true
+		missing = append(missing, string(p.prefix)+name)
+	}
+
+	switch len(missing) {
+	case 0:
+	case 1:
+		return fmt.Errorf("Missing value for parameter %s", missing[0])
+	default:
+		return fmt.Errorf("Missing values for parameters %s", strings.Join(missing, ", "))
+	}
+
+	misplaced = p.misplacedExpansions(omittedOccurrences)
+
+	switch len(misplaced) {
+	case 0:
+		return nil
+	case 1:
+		return fmt.Errorf("Unable to expand parameter %s: a slice value must be used inside a parenthesized list, e.g. IN (%s)", misplaced[0], misplaced[0])
+	default:
+		return fmt.Errorf("Unable to expand parameters %s: slice values must be used inside a parenthesized list, e.g. IN (%s)", strings.Join(misplaced, ", "), misplaced[0])
+	}
+}
+
+// ParameterNames returns the distinct named parameters of p query, in the order
+// they first appear in the original query text.
+func (p *parser) ParameterNames() []string {
+
+	var names []string
+
+	names = make([]string, p.positions.len())
+	copy(names, p.positions.names)
+
+	return names
+}
+
+// ParameterCount returns the number of times the given [parameterName] occurs
+// in p query, or 0 if p query does not contain it.
+func (p *parser) ParameterCount(parameterName string) int {
+
+	var count int
+
+	parameterName = p.normalizeName(parameterName)
+
+	if !p.deduplicates() {
+		return len(p.positions.get(parameterName))
+	}
+
+	for _, position := range p.occurrences {
+		if p.positionNames[position] == parameterName {
+			count++
+		}
+	}
+	return count
+}
+
+// ParameterOffsets returns the byte offsets in the original query of every
+// occurrence of each named parameter of p query, keyed by name, e.g. 7 for
+// ":foo" in "SELECT :foo", so that tooling can highlight parameters in the
+// source text. Each offset is that of the parameter's prefix, and every slice
+// is in ascending order.
+func (p *parser) ParameterOffsets() map[string][]int {
+
+	var offsets map[string][]int
+	var name string
+
+	offsets = make(map[string][]int, p.positions.len())
+
+	for occurrence, position := range p.occurrences {
+
+		name = p.positionNames[position]
+
+		if len(name) > 0 {
+			offsets[name] = append(offsets[name], p.offsets[occurrence])
+		}
+	}
+	return offsets
+}
+
+// QuotedParameterLikeTokens returns every distinct token inside a string literal
+// of p query which looks like a named parameter, e.g. ":name" in 'hello :name',
+// in the order they appear. Such tokens are not parameters, so this can be used
+// to warn about a parameter which was mistakenly quoted. It does not affect how
+// the query is parsed.
+//
+// The returned slice is a copy, and may be modified freely.
+func (p *parser) QuotedParameterLikeTokens() []string {
+
+	var tokens []string
+
+	if len(p.quotedTokens) <= 0 {
+		return nil
+	}
+
+	tokens = make([]string, len(p.quotedTokens))
+	copy(tokens, p.quotedTokens)
+
+	return tokens
+}
+
+// GetDialect returns the dialect whose placeholder syntax p emits, as given to
+// NewParserForDriver or WithDriver. The default dialect is DialectPostgres.
+func (p *parser) GetDialect() Dialect {
+	return p.dialect
+}
+
+// HasParameter returns true if p query contains the given [parameterName], e.g.
+// to avoid computing a value which the query does not use.
+func (p *parser) HasParameter(parameterName string) bool {
+	return p.positions.has(p.normalizeName(parameterName))
+}
+
+// NumParameters returns the number of positional parameters of the revised query,
+// i.e. the length of the slice returned by GetParsedParameters, without building
+// it. Every element of an expanded slice value counts as a parameter.
+func (p *parser) NumParameters() int {
+
+	var count int
+	var omitted []bool
+
+	if !p.isRewritten() {
+		return len(p.parameters)
+	}
+
+	omitted = p.omittedPositions(p.omittedOccurrences())
+
+	for position := range p.parameters {
+
+		if omitted != nil && omitted[position] {
+			continue
+		}
+
+		if p.expansions[position] != nil {
+			count += len(p.expansions[position])
+		} else {
+			count++
+		}
+	}
+	return count
+}
+
+// Positions returns the 1-based numbers of the placeholders which the given
+// [parameterName] was replaced by in the revised query, i.e. N for every "$N",
+// or nil if p query does not contain it. If the parameter is bound to a slice
+// value, every placeholder it is expanded into is included, and if it is part of
+// an omitted optional clause, it has no placeholders.
+//
+// The returned slice is a copy, and may be modified freely.
+func (p *parser) Positions(parameterName string) []int {
+
+	var positions []int
+	var starts []int
+	var omitted []bool
+	var count int
+
+	if !p.positions.has(p.normalizeName(parameterName)) {
+		return nil
+	}
+
+	omitted = p.omittedPositions(p.omittedOccurrences())
+	starts = p.placeholderStarts(omitted)
+
+	for _, position := range p.positions.get(p.normalizeName(parameterName)) {
+
+		if omitted != nil && omitted[position] {
+			continue
+		}
+
+		count = 1
+
+		if p.expansions[position] != nil {
+			count = len(p.expansions[position])
+		}
+
+		for j := 0; j < count; j++ {
+			positions = append(positions, starts[position]+j)
+		}
+	}
+	return positions
+}
+
+// ResetValues clears every value set on p query, so that its parameters are all
+// unset again. The parsed query is kept, so the parser can be reused with new
+// values without parsing the query text again.
+func (p *parser) ResetValues() {
+
+	for position := range p.parameters {
+		p.parameters[position] = nil
+		p.assigned[position] = false
+		p.expansions[position] = nil
+	}
+
+	p.applyDefaults()
+}
+
+// Reset returns p to the state of a parser created with an empty query: its query,
+// values and defaults are removed, but the options it was created with are kept.
+// The memory of p is kept for reuse where possible, so that parsers can be recycled
+// with a sync.Pool, e.g.
+//
+// 	var parsers = sync.Pool{
+// 		New: func() interface{} {
+// 			return NewParser("")
+// 		},
+// 	}
+//
+// 	prsr := parsers.Get().(Parser)
+// 	prsr.Reparse(queryText)
+// 	prsr.SetValue("foo", "bar")
+// 	rows, err := connection.Query(prsr.GetParsedQuery(), prsr.GetParsedParameters()...)
+// 	prsr.Reset()
+// 	parsers.Put(prsr)
+//
+// The parameters returned by GetParsedParameters must not be used after Reset.
+func (p *parser) Reset() {
+
+	for name := range p.defaults {
+		delete(p.defaults, name)
+	}
+
+	p.Reparse("")
+}
+
+// SetDefault sets a default value for the given [parameterName], which is used
+// for every position of it which has not been explicitly set, instead of nil.
+// A value set with SetValue always takes precedence over the default, and the
+// default is restored by ResetValues. A parameter with a default is never
+// reported as missing by Validate.
+func (p *parser) SetDefault(parameterName string, parameterValue interface{}) {
+
+	if p.defaults == nil {
+		p.defaults = make(map[string]interface{}, 4)
+	}
+
+	p.defaults[p.normalizeName(parameterName)] = parameterValue
+	p.applyDefaults()
+}
+
+// applyDefaults sets every position which has not been explicitly set to the
+// default value of its parameter, if it has one.
+func (p *parser) applyDefaults() {
+
+	for name, value := range p.defaults {
+
+		for _, position := range p.positions.get(name) {
+
+			if !p.assigned[position] {
+				p.parameters[position] = p.transform(value)
+				p.expansions[position] = p.expansion(p.parameters[position])
+			}
+		}
+	}
+}
+
+// Clone returns a new parser for the same parsed query as p, with all of its
+// parameters unset, but with the same defaults. The clone shares the parsed
+// query with p instead of parsing it again, but binds its values independently,
+// so p and its clones may be used from different goroutines.
+//
+// The positions store and segments are shared read-only between p and its
+// clones; neither may modify them once cloned.
+func (p *parser) Clone() Parser {
+
+	var clone *parser
+
+	clone = &parser{}
+	clone.dialect = p.dialect
+	clone.placeholderFormat = p.placeholderFormat
+	clone.placeholderOffset = p.placeholderOffset
+	clone.prefix = p.prefix
+	clone.originalQuery = p.originalQuery
+	clone.revisedQuery = p.revisedQuery
+	clone.rendered = p.rendered
+	clone.positions = p.positions
+	clone.positionNames = p.positionNames
+	clone.segments = p.segments
+	clone.occurrences = p.occurrences
+	clone.offsets = p.offsets
+	clone.quotedTokens = p.quotedTokens
+	clone.statements = p.statements
+	clone.clauses = p.clauses
+	clone.ignoreQuotes = p.ignoreQuotes
+	clone.streamed = p.streamed
+	clone.optionalClauses = p.optionalClauses
+	clone.statementNumbering = p.statementNumbering
+	clone.deduplicate = p.deduplicate
+	clone.caseInsensitive = p.caseInsensitive
+	clone.checkDuplicateFields = p.checkDuplicateFields
+	clone.jsonTagFallback = p.jsonTagFallback
+	clone.positionalPassthrough = p.positionalPassthrough
+	clone.numberedPassthrough = p.numberedPassthrough
+	clone.existingPlaceholders = p.existingPlaceholders
+	clone.nameValidator = p.nameValidator
+	clone.resolveValuers = p.resolveValuers
+	clone.unwrapNulls = p.unwrapNulls
+	clone.underlyingTypes = p.underlyingTypes
+	clone.blankStringsAsNull = p.blankStringsAsNull
+	clone.normalizeNils = p.normalizeNils
+
+	clone.shared = 1
+	atomic.StoreInt32(&p.shared, 1)
+
+	clone.parameters = make([]interface{}, len(p.parameters))
+	clone.assigned = make([]bool, len(p.assigned))
+	clone.expansions = make([][]interface{}, len(p.expansions))
+
+	if p.defaults != nil {
+
+		clone.defaults = make(map[string]interface{}, len(p.defaults))
+
+		for name, value := range p.defaults {
+			clone.defaults[name] = value
+		}
+		clone.applyDefaults()
+	}
+
+	return clone
+}
+
+// Reparse replaces the query of p with the given [queryText], in the same way as
+// if p had been created with it, and unsets every parameter. The memory used for
+// the previous query is reused where possible, unless it is shared with a clone.
+func (p *parser) Reparse(queryText string) {
+
+	if atomic.LoadInt32(&p.shared) != 0 {
+		p.positions = positionStore{}
+		p.positionNames = nil
+		p.segments = nil
+		p.occurrences = nil
+		p.offsets = nil
+		p.quotedTokens = nil
+		p.statements = nil
+		p.clauses = nil
+		p.shared = 0
+	} else {
+
+		p.positions.reset()
+		p.positionNames = p.positionNames[:0]
+		p.segments = p.segments[:0]
+		p.occurrences = p.occurrences[:0]
+		p.offsets = p.offsets[:0]
+		p.quotedTokens = p.quotedTokens[:0]
+		p.statements = p.statements[:0]
+		p.clauses = p.clauses[:0]
+	}
+
+	p.streamed = false
+	p.setQuery(queryText)
+	p.applyDefaults()
+}
+
+// String returns a readable summary of p, for debugging: the revised query on
+// the first line, followed by a line for each parameter with its current value,
+// in the order the parameters first appear in the query.
+func (p *parser) String() string {
+
+	var builder bytes.Buffer
+	var printed map[string]bool
+	var name string
+
+	printed = make(map[string]bool, p.positions.len())
+	builder.WriteString(p.GetParsedQuery())
+
+	for position, parameter := range p.parameters {
+
+		name = p.positionNames[position]
+
+		if len(name) <= 0 {
+			name = "?" + strconv.Itoa(position+1)
+		} else if printed[name] {
+			continue
+		} else {
+			printed[name] = true
+			name = string(p.prefix) + name
+		}
+
+		if !p.assigned[position] && parameter == nil {
+			fmt.Fprintf(&builder, "\n%s = <unset>", name)
+		} else {
+			fmt.Fprintf(&builder, "\n%s = %#v", name, parameter)
+		}
+	}
+	return builder.String()
 }
@@ -1,7 +1,13 @@
 package npq
 
 import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 )
 
 // QueryParsingTest represents a single test of prsr parsing. Given an [Input]
@@ -30,7 +36,7 @@ type TestQueryParameter struct {
 	Value interface{}
 }
 
-func TestqueryParsing(test *testing.T) {
+func TestQueryParsing(test *testing.T) {
 
 	var prsr Parser
 
@@ -100,12 +106,198 @@ func TestqueryParsing(test *testing.T) {
 			ExpectedParameters: 1,
 			Name:               "AltcapsParameters",
 		},
+		QueryParsingTest{
+			Input:              "SELECT * FROM table -- :note deprecated\nWHERE col1 = :foo AND col2 = :bar",
+			Expected:           "SELECT * FROM table -- :note deprecated\nWHERE col1 = ? AND col2 = ?",
+			ExpectedParameters: 2,
+			Name:               "ParametersInLineComment",
+		},
+		QueryParsingTest{
+			Input:              "SELECT * FROM table WHERE col1 = :foo -- trailing :comment",
+			Expected:           "SELECT * FROM table WHERE col1 = ? -- trailing :comment",
+			ExpectedParameters: 1,
+			Name:               "ParametersInTrailingLineComment",
+		},
+		QueryParsingTest{
+			Input:              "SELECT * FROM table WHERE col1 = '-- not a comment' AND col2 = :foo",
+			Expected:           "SELECT * FROM table WHERE col1 = '-- not a comment' AND col2 = ?",
+			ExpectedParameters: 1,
+			Name:               "LineCommentInQuotes",
+		},
+		QueryParsingTest{
+			Input:              "SELECT * FROM table WHERE col1 = 5 - :foo",
+			Expected:           "SELECT * FROM table WHERE col1 = 5 - ?",
+			ExpectedParameters: 1,
+			Name:               "SingleDash",
+		},
+		QueryParsingTest{
+			Input:              "SELECT * FROM table /* :note\n:other */ WHERE col1 = :foo",
+			Expected:           "SELECT * FROM table /* :note\n:other */ WHERE col1 = ?",
+			ExpectedParameters: 1,
+			Name:               "ParametersInBlockComment",
+		},
+		QueryParsingTest{
+			Input:              "SELECT * FROM table WHERE col1 = :foo/**/AND col2 = :bar /*/ :baz */",
+			Expected:           "SELECT * FROM table WHERE col1 = ?/**/AND col2 = ? /*/ :baz */",
+			ExpectedParameters: 2,
+			Name:               "AdjacentBlockComments",
+		},
+		QueryParsingTest{
+			Input:              "SELECT * FROM table WHERE col1 = :foo /* unterminated :bar",
+			Expected:           "SELECT * FROM table WHERE col1 = ? /* unterminated :bar",
+			ExpectedParameters: 1,
+			Name:               "UnterminatedBlockComment",
+		},
+		QueryParsingTest{
+			Input:              "SELECT * FROM table WHERE col1 = '/* :literal */' AND col2 = :foo / 2",
+			Expected:           "SELECT * FROM table WHERE col1 = '/* :literal */' AND col2 = ? / 2",
+			ExpectedParameters: 1,
+			Name:               "BlockCommentInQuotes",
+		},
+		QueryParsingTest{
+			Input:              "SELECT :id::int",
+			Expected:           "SELECT ?::int",
+			ExpectedParameters: 1,
+			Name:               "CastAfterParameter",
+		},
+		QueryParsingTest{
+			Input:              "SELECT * FROM table WHERE col1::text = :foo AND col2 = '1'::int",
+			Expected:           "SELECT * FROM table WHERE col1::text = ? AND col2 = '1'::int",
+			ExpectedParameters: 1,
+			Name:               "CastsWithoutParameters",
+		},
+		QueryParsingTest{
+			Input:              "SELECT \"weird:column\" FROM table WHERE col1 = :foo",
+			Expected:           "SELECT \"weird:column\" FROM table WHERE col1 = ?",
+			ExpectedParameters: 1,
+			Name:               "ParametersInQuotedIdentifier",
+		},
+		QueryParsingTest{
+			Input:              "SELECT \"a\"\":b\" FROM table WHERE col1 = :foo",
+			Expected:           "SELECT \"a\"\":b\" FROM table WHERE col1 = ?",
+			ExpectedParameters: 1,
+			Name:               "EscapedQuoteInQuotedIdentifier",
+		},
+		QueryParsingTest{
+			Input:              "SELECT \"it's\" FROM table WHERE col1 = :foo",
+			Expected:           "SELECT \"it's\" FROM table WHERE col1 = ?",
+			ExpectedParameters: 1,
+			Name:               "SingleQuoteInQuotedIdentifier",
+		},
+		QueryParsingTest{
+			Input:              "SELECT * FROM table WHERE col1 = 'it''s' AND col2 = :foo",
+			Expected:           "SELECT * FROM table WHERE col1 = 'it''s' AND col2 = ?",
+			ExpectedParameters: 1,
+			Name:               "EscapedQuote",
+		},
+		QueryParsingTest{
+			Input:              "SELECT * FROM table WHERE col1 = 'it''s :not ''a'' :param''' AND col2 = :foo AND col3 = ''''",
+			Expected:           "SELECT * FROM table WHERE col1 = 'it''s :not ''a'' :param''' AND col2 = ? AND col3 = ''''",
+			ExpectedParameters: 1,
+			Name:               "MultipleEscapedQuotes",
+		},
+		QueryParsingTest{
+			Input:              "SELECT * FROM table WHERE col1 = '' AND col2 = :foo AND col3 = ''",
+			Expected:           "SELECT * FROM table WHERE col1 = '' AND col2 = ? AND col3 = ''",
+			ExpectedParameters: 1,
+			Name:               "EmptyLiterals",
+		},
+		QueryParsingTest{
+			Input:              "SELECT * FROM table WHERE col1 = : foo AND col2 = :bar",
+			Expected:           "SELECT * FROM table WHERE col1 = : foo AND col2 = ?",
+			ExpectedParameters: 1,
+			Name:               "BareColon",
+		},
+		QueryParsingTest{
+			Input:              "SELECT * FROM table WHERE col1 = :bar AND col2 = :",
+			Expected:           "SELECT * FROM table WHERE col1 = ? AND col2 = :",
+			ExpectedParameters: 1,
+			Name:               "TrailingBareColon",
+		},
+		QueryParsingTest{
+			Input:              "SELECT * FROM table WHERE col1 = ':' || :bar || :(",
+			Expected:           "SELECT * FROM table WHERE col1 = ':' || ? || :(",
+			ExpectedParameters: 1,
+			Name:               "ColonBeforePunctuation",
+		},
+		QueryParsingTest{
+			Input:              "SELECT * FROM t WHERE id = :id",
+			Expected:           "SELECT * FROM t WHERE id = ?",
+			ExpectedParameters: 1,
+			Name:               "ParameterAtEnd",
+		},
+		QueryParsingTest{
+			Input:              ":id",
+			Expected:           "?",
+			ExpectedParameters: 1,
+			Name:               "ParameterOnly",
+		},
+		QueryParsingTest{
+			Input:              "SELECT :a,:b",
+			Expected:           "SELECT ?,?",
+			ExpectedParameters: 2,
+			Name:               "AdjacentParametersAtEnd",
+		},
+		QueryParsingTest{
+			Input:              "SELECT * FROM t WHERE name = :user.name",
+			Expected:           "SELECT * FROM t WHERE name = ?",
+			ExpectedParameters: 1,
+			Name:               "NestedParameterAtEnd",
+		},
+		QueryParsingTest{
+			Input:              "INSERT INTO t VALUES(:a,:b)",
+			Expected:           "INSERT INTO t VALUES(?,?)",
+			ExpectedParameters: 2,
+			Name:               "ParametersInValuesWithoutSpaces",
+		},
+		QueryParsingTest{
+			Input:              "UPDATE t SET a=:a WHERE id=:id;SELECT 1",
+			Expected:           "UPDATE t SET a=? WHERE id=?;SELECT 1",
+			ExpectedParameters: 2,
+			Name:               "ParameterBeforeSemicolon",
+		},
+		QueryParsingTest{
+			Input:              "SELECT * FROM t WHERE a=:a+:b-:c*:d/:e%:f<:g>:h<>:i||:j",
+			Expected:           "SELECT * FROM t WHERE a=?+?-?*?/?%?<?>?<>?||?",
+			ExpectedParameters: 10,
+			Name:               "ParametersBetweenOperators",
+		},
+		QueryParsingTest{
+			Input:              "SELECT f(:a),(:b),[:c],{:d},:e!",
+			Expected:           "SELECT f(?),(?),[?],{?},?!",
+			ExpectedParameters: 5,
+			Name:               "ParametersInBrackets",
+		},
+		QueryParsingTest{
+			Input:              "SELECT :a.,:b.)",
+			Expected:           "SELECT ?.,?.)",
+			ExpectedParameters: 2,
+			Name:               "ParametersBeforeDotAndPunctuation",
+		},
+		QueryParsingTest{
+			Input:              "SELECT :a'x',:b\"y\",:c--z\n,:d/*w*/",
+			Expected:           "SELECT ?'x',?\"y\",?--z\n,?/*w*/",
+			ExpectedParameters: 4,
+			Name:               "ParametersBeforeQuotesAndComments",
+		},
+		QueryParsingTest{
+			Input:              "SELECT * FROM table_:{id}s WHERE col1 = :{user.name}x AND col2 = :{foo}",
+			Expected:           "SELECT * FROM table_?s WHERE col1 = ?x AND col2 = ?",
+			ExpectedParameters: 3,
+			Name:               "BracedParameters",
+		},
+		QueryParsingTest{
+			Input:              "SELECT :{}, :{a b}, :{a., :{a",
+			Expected:           "SELECT :{}, :{a b}, :{a., :{a",
+			ExpectedParameters: 0,
+			Name:               "InvalidBracedParameters",
+		},
 	}
 
 	// Run each test.
 	for _, parsingTest := range QueryParsingTests {
 
-		prsr = NewParser(parsingTest.Input)
+		prsr = NewParserForDriver(parsingTest.Input, DriverMySQL)
 
 		// test prsr texts
 		if prsr.GetParsedQuery() != parsingTest.Expected {
@@ -359,3 +551,1681 @@ func verifyStructParameters(testName string, test *testing.T, prsr Parser, expec
 
 	test.Logf("Run %d struct reflection parameter tests", actualParameterLength)
 }
+
+func TestValidate(test *testing.T) {
+
+	var prsr Parser
+	var err error
+
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :foo AND col2 = :email AND col3 = :name AND col4 = :email")
+	prsr.SetValue("foo", nil)
+
+	err = prsr.Validate()
+
+	if err == nil || err.Error() != "Missing values for parameters :email, :name" {
+		test.Log("Test 'MultipleMissing': Expected an error naming each missing parameter once. Actual: ", err)
+		test.Fail()
+	}
+
+	prsr.SetValue("name", "Alice")
+	err = prsr.Validate()
+
+	if err == nil || err.Error() != "Missing value for parameter :email" {
+		test.Log("Test 'SingleMissing': Expected an error naming the missing parameter. Actual: ", err)
+		test.Fail()
+	}
+
+	prsr.SetValue("email", "alice@example.com")
+	err = prsr.Validate()
+
+	if err != nil {
+		test.Log("Test 'NoneMissing': Expected no error once every parameter is set. Actual: ", err)
+		test.Fail()
+	}
+}
+
+func TestParameterNames(test *testing.T) {
+
+	var prsr Parser
+	var names []string
+
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :foo AND col2 = :bar AND col3 = :foo AND col4 = :baz")
+	names = prsr.ParameterNames()
+	expected := []string{"foo", "bar", "baz"}
+
+	if len(names) != len(expected) {
+		test.Log("Expected ", len(expected), " parameter names, got ", names)
+		test.FailNow()
+	}
+
+	for index, name := range names {
+		if name != expected[index] {
+			test.Log("Parameter name at index ", index, " (", name, ") did not match expected name (", expected[index], ")")
+			test.Fail()
+		}
+	}
+
+	prsr = NewParser("SELECT * FROM table")
+
+	if len(prsr.ParameterNames()) != 0 {
+		test.Log("Expected no parameter names for a query without parameters, got ", prsr.ParameterNames())
+		test.Fail()
+	}
+}
+
+func TestResetValues(test *testing.T) {
+
+	var prsr Parser
+
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :foo AND col2 IN (:ids)")
+	prsr.SetValue("foo", "bar")
+	prsr.SetValue("ids", []int{1, 2})
+	prsr.ResetValues()
+
+	if prsr.GetParsedQuery() != "SELECT * FROM table WHERE col1 = $1 AND col2 IN ($2)" {
+		test.Log("Expected the parsed query to be kept without expansions. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+
+	for index, parameter := range prsr.GetParsedParameters() {
+		if parameter != nil {
+			test.Log("Expected parameter at position ", index, " to be cleared. Actual: ", parameter)
+			test.Fail()
+		}
+	}
+
+	if prsr.Validate() == nil {
+		test.Log("Expected every parameter to be unset after ResetValues")
+		test.Fail()
+	}
+
+	prsr.SetValue("foo", "baz")
+
+	if prsr.GetParsedParameters()[0] != "baz" {
+		test.Log("Expected the parser to be reusable after ResetValues. Actual: ", prsr.GetParsedParameters())
+		test.Fail()
+	}
+}
+
+func TestCloneConcurrentValues(test *testing.T) {
+
+	var prsr Parser
+	var waitGroup sync.WaitGroup
+
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :foo AND col2 = :bar")
+
+	for i := 0; i < 16; i++ {
+
+		waitGroup.Add(1)
+
+		go func(value int) {
+
+			defer waitGroup.Done()
+
+			clone := prsr.Clone()
+			clone.SetValue("foo", value)
+			clone.SetValue("bar", strconv.Itoa(value))
+
+			parameters := clone.GetParsedParameters()
+
+			if parameters[0] != value || parameters[1] != strconv.Itoa(value) {
+				test.Log("Clone ", value, " did not keep its own values. Actual: ", parameters)
+				test.Fail()
+			}
+		}(i)
+	}
+
+	waitGroup.Wait()
+
+	for index, parameter := range prsr.GetParsedParameters() {
+		if parameter != nil {
+			test.Log("Expected original parameter at position ", index, " to be unaffected by its clones. Actual: ", parameter)
+			test.Fail()
+		}
+	}
+}
+
+func TestCloneIsolatesValues(test *testing.T) {
+
+	var prsr Parser
+	var clone Parser
+
+	prsr = NewParserForDriver("SELECT * FROM table WHERE col1 = :foo AND col2 IN (:ids)", DriverMySQL)
+	prsr.SetValue("foo", "original")
+
+	clone = prsr.Clone()
+
+	if clone.GetParsedQuery() != prsr.GetParsedQuery() {
+		test.Log("Expected the clone to share the parsed query. Actual: ", clone.GetParsedQuery())
+		test.Fail()
+	}
+
+	if clone.Validate() == nil {
+		test.Log("Expected values set before cloning not to be copied to the clone")
+		test.Fail()
+	}
+
+	clone.SetValue("ids", []int{1, 2, 3})
+
+	if prsr.GetParsedQuery() != "SELECT * FROM table WHERE col1 = ? AND col2 IN (?)" {
+		test.Log("Expected an expansion in the clone not to affect the original. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+
+	if clone.GetParsedQuery() != "SELECT * FROM table WHERE col1 = ? AND col2 IN (?, ?, ?)" {
+		test.Log("Expected the clone to expand its own values. Actual: ", clone.GetParsedQuery())
+		test.Fail()
+	}
+
+	if prsr.GetParsedParameters()[0] != "original" {
+		test.Log("Expected the original to keep its values. Actual: ", prsr.GetParsedParameters())
+		test.Fail()
+	}
+}
+
+func TestNewParserStrict(test *testing.T) {
+
+	var prsr Parser
+	var err error
+
+	malformedQueries := map[string]string{
+		"UnterminatedQuote":        "SELECT * FROM table WHERE col1 = 'foo AND col2 = :bar",
+		"UnterminatedIdentifier":   "SELECT \"col1 FROM table WHERE col2 = :bar",
+		"UnterminatedEscapedQuote": "SELECT * FROM table WHERE col1 = 'it''",
+		"UnterminatedComment":      "SELECT * FROM table WHERE col1 = :foo /* comment",
+		"EmptyParameterName":       "SELECT * FROM table WHERE col1 = : foo",
+		"EmptyTrailingParameter":   "SELECT * FROM table WHERE col1 = :",
+		"UnterminatedDollarQuote":  "SELECT $body$ :foo $$ :bar",
+	}
+
+	for name, query := range malformedQueries {
+
+		prsr, err = NewParserStrict(query)
+
+		if err == nil || prsr != nil {
+			test.Log("Test '", name, "': Expected an error for malformed query '", query, "'")
+			test.Fail()
+		}
+	}
+
+	prsr, err = NewParserStrict("SELECT * FROM table WHERE col1 = 'it''s' AND col2 = :foo::int -- :comment")
+
+	if err != nil {
+		test.Log("Test 'WellFormed': Expected no error. Actual: ", err)
+		test.FailNow()
+	}
+
+	if prsr.GetParsedQuery() != "SELECT * FROM table WHERE col1 = 'it''s' AND col2 = $1::int -- :comment" {
+		test.Log("Test 'WellFormed': Unexpected parsed output: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+
+	prsr, err = NewParserStrict("DO $$ BEGIN x := 1; END $$; SELECT * FROM table WHERE col1 := :foo AND col2 = :bar:=1")
+
+	if err != nil {
+		test.Log("Test 'Assignment': Expected no error. Actual: ", err)
+		test.FailNow()
+	}
+
+	if prsr.GetParsedQuery() != "DO $$ BEGIN x := 1; END $$; SELECT * FROM table WHERE col1 := $1 AND col2 = $2:=1" {
+		test.Log("Test 'Assignment': Unexpected parsed output: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+}
+
+// The lenient parser must not hang, and must keep the text of an unterminated quote.
+func TestUnterminatedQuote(test *testing.T) {
+
+	var prsr Parser
+
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :foo AND col2 = 'bar :baz")
+
+	if prsr.GetParsedQuery() != "SELECT * FROM table WHERE col1 = $1 AND col2 = 'bar :baz" {
+		test.Log("Unexpected parsed output for an unterminated quote: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+
+	// a trailing unbalanced quote must end the quoted region at the end of the query,
+	// keeping its text, instead of reading past it.
+	trailingQuotes := map[string]string{
+		"SELECT :foo, '":     "SELECT $1, '",
+		"SELECT :foo, \"":    "SELECT $1, \"",
+		"SELECT :foo, ''' ":  "SELECT $1, ''' ",
+		"SELECT :foo, 'it''": "SELECT $1, 'it''",
+	}
+
+	for query, expected := range trailingQuotes {
+
+		prsr = NewParser(query)
+
+		if prsr.GetParsedQuery() != expected {
+			test.Log("Unexpected parsed output for a trailing quote: ", prsr.GetParsedQuery())
+			test.Fail()
+		}
+	}
+
+	prsr = NewParserForDriver("SELECT :foo, '\\", DriverMySQL)
+
+	if prsr.GetParsedQuery() != "SELECT ?, '\\" {
+		test.Log("Unexpected parsed output for a trailing backslash: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+}
+
+func TestParseNamed(test *testing.T) {
+
+	var prsr Parser
+	var names []string
+
+	prsr, names = ParseNamed("SELECT * FROM table WHERE col1 = :foo AND col2 = :bar AND col3 = :foo AND col4 = :baz")
+
+	if len(names) != 3 || names[0] != "foo" || names[1] != "bar" || names[2] != "baz" {
+		test.Log("Expected the distinct names in appearance order. Actual: ", names)
+		test.Fail()
+	}
+
+	if prsr.GetParsedQuery() != "SELECT * FROM table WHERE col1 = $1 AND col2 = $2 AND col3 = $3 AND col4 = $4" {
+		test.Log("Expected the query to be parsed as by NewParser. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+}
+
+func TestNewParserWithOffset(test *testing.T) {
+
+	var prsr Parser
+	var positions []int
+
+	prsr = NewParserWithOffset("col1 = :foo AND col2 IN (:ids) AND col3 = :foo", 3)
+	prsr.SetValue("ids", []int{1, 2})
+
+	if prsr.GetParsedQuery() != "col1 = $4 AND col2 IN ($5, $6) AND col3 = $7" {
+		test.Log("Expected the placeholders to be numbered after the offset. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+
+	positions = prsr.Positions("foo")
+
+	if len(positions) != 2 || positions[0] != 4 || positions[1] != 7 {
+		test.Log("Expected the positions to reflect the offset. Actual: ", positions)
+		test.Fail()
+	}
+
+	if prsr.Clone().GetParsedQuery() != "col1 = $4 AND col2 IN ($5) AND col3 = $6" {
+		test.Log("Expected a clone to keep the offset. Actual: ", prsr.Clone().GetParsedQuery())
+		test.Fail()
+	}
+
+	prsr = NewParserWithOffset("col1 = :foo", -1)
+
+	if prsr.GetParsedQuery() != "col1 = $1" {
+		test.Log("Expected a negative offset to be ignored. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+}
+
+func TestBracedParameters(test *testing.T) {
+
+	var prsr Parser
+	var names []string
+	var err error
+
+	prsr, names = ParseNamed("SELECT * FROM table_:{id}s WHERE col1 = :id AND col2 = :{user.name}x")
+	prsr.SetValue("id", 1)
+
+	if len(names) != 2 || names[0] != "id" || names[1] != "user.name" {
+		test.Log("Expected the names inside the braces. Actual: ", names)
+		test.Fail()
+	}
+
+	verifyStructParameters("BracedParameters", test, prsr, []interface{}{1, 1, nil})
+
+	if offsets := prsr.ParameterOffsets()["user.name"]; len(offsets) != 1 || offsets[0] != 55 {
+		test.Log("Expected the offset of the braced prefix. Actual: ", offsets)
+		test.Fail()
+	}
+
+	// a named dialect writes the names without braces.
+	prsr, err = NewParserStrictWithOptions("SELECT :{id}, :a, :{b})", WithDriver(DialectNamed))
+
+	if err != nil || prsr.GetParsedQuery() != "SELECT :id, :a, :b)" {
+		test.Log("Expected braced names to be written without braces. Actual: ", err)
+		test.Fail()
+	}
+
+	for _, query := range []string{"SELECT :{id}s", "SELECT :{user}.name"} {
+
+		if _, err = NewParserStrictWithOptions(query, WithDriver(DialectNamed)); err == nil {
+			test.Log("Expected an error for a braced name followed by name characters in the named dialect: ", query)
+			test.Fail()
+		}
+	}
+
+	if _, err = NewParserStrictWithOptions("SELECT :{id}s", WithDriver(DialectPostgres)); err != nil {
+		test.Log("Expected a braced name followed by name characters in a positional dialect. Actual: ", err)
+		test.Fail()
+	}
+}
+
+func TestWith(test *testing.T) {
+
+	var prsr Parser
+
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :a AND col2 = :b AND col3 = :a").With("a", 1).With("b", 2)
+
+	verifyStructParameters("ChainedWith", test, prsr, []interface{}{
+		1,
+		2,
+		1,
+	})
+
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :a AND col2 = :b AND col3 = :c").WithValues(map[string]interface{}{"a": 1, "b": 2}).With("c", 3)
+
+	verifyStructParameters("ChainedWithValues", test, prsr, []interface{}{
+		1,
+		2,
+		3,
+	})
+}
+
+type AuditColumns struct {
+	CreatedBy string
+	UpdatedBy string `sqlParameterName:"updater"`
+}
+
+type auditVersion struct {
+	Version int
+}
+
+type Period struct {
+	Start int
+	End   int
+}
+
+type EmbeddedParameterTest struct {
+	AuditColumns
+	auditVersion
+	Period `sqlParameterName:"period"`
+	Name   string
+	Range  Period
+}
+
+func TestEmbeddedStructParameters(test *testing.T) {
+
+	var prsr Parser
+	var embeddedParam EmbeddedParameterTest
+
+	embeddedParam.CreatedBy = "alice"
+	embeddedParam.UpdatedBy = "bob"
+	embeddedParam.Version = 3
+	embeddedParam.Period = Period{1, 2}
+	embeddedParam.Name = "eve"
+	embeddedParam.Range = Period{3, 4}
+
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :CreatedBy AND col2 = :updater AND col3 = :Version AND col4 = :Name AND col5 = :Start AND col6 = :period AND col7 = :Range")
+	prsr.SetValuesFromStruct(embeddedParam)
+
+	verifyStructParameters("EmbeddedStructReplacement", test, prsr, []interface{}{
+		"alice",
+		"bob",
+		3,
+		"eve",
+		nil,
+		Period{1, 2},
+		Period{3, 4},
+	})
+}
+
+type PointerParameterTest struct {
+	Name    *string
+	Age     *int
+	Missing *string
+	Double  **int
+}
+
+func TestPointerStructParameters(test *testing.T) {
+
+	var prsr Parser
+	var pointerParam PointerParameterTest
+
+	name := "alice"
+	age := 30
+	agePointer := &age
+
+	pointerParam.Name = &name
+	pointerParam.Age = &age
+	pointerParam.Double = &agePointer
+
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :Name AND col2 = :Age AND col3 = :Missing AND col4 = :Double")
+	prsr.SetValuesFromStruct(pointerParam)
+
+	verifyStructParameters("PointerStructReplacement", test, prsr, []interface{}{
+		"alice",
+		30,
+		nil,
+		30,
+	})
+
+	if prsr.Validate() != nil {
+		test.Log("Expected a nil pointer field to count as set. Actual: ", prsr.Validate())
+		test.Fail()
+	}
+}
+
+func TestStructPointerParameters(test *testing.T) {
+
+	var prsr Parser
+	var singleParam SingleParameterTest
+	var nilParam *SingleParameterTest
+	var err error
+
+	singleParam.Foo = "foo"
+	singleParam.Bar = "bar"
+	singleParam.Baz = 15
+
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :Foo AND col2 = :Bar AND col3 = :Baz")
+	err = prsr.SetValuesFromStruct(&singleParam)
+
+	if err != nil {
+		test.Log("Expected a pointer to a struct to be accepted. Actual: ", err)
+		test.Fail()
+	}
+
+	verifyStructParameters("StructPointerReplacement", test, prsr, []interface{}{
+		"foo",
+		"bar",
+		15,
+	})
+
+	err = prsr.SetValuesFromStruct(nilParam)
+
+	if err == nil {
+		test.Log("Expected an error for a nil pointer to a struct")
+		test.Fail()
+	}
+
+	err = prsr.SetValuesFromStruct(&err)
+
+	if err == nil {
+		test.Log("Expected an error for a pointer to a non-struct")
+		test.Fail()
+	}
+}
+
+type SkippedParameterTest struct {
+	Foo          string
+	Bar          string `sqlParameterName:"-"`
+	AuditColumns `sqlParameterName:"-"`
+}
+
+func TestSkippedStructParameters(test *testing.T) {
+
+	var prsr Parser
+	var skippedParam SkippedParameterTest
+
+	skippedParam.Foo = "foo"
+	skippedParam.Bar = "bar"
+	skippedParam.CreatedBy = "alice"
+
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :Foo AND col2 = :Bar AND col3 = :CreatedBy AND col4 = :-")
+	prsr.SetValuesFromStruct(skippedParam)
+
+	verifyStructParameters("SkippedStructReplacement", test, prsr, []interface{}{
+		"foo",
+		nil,
+		nil,
+	})
+}
+
+type TaggedParameterTest struct {
+	Foo     string `db:"foo,omitempty" sqlParameterName:"other"`
+	Bar     string `db:"-"`
+	Baz     string
+	Details struct {
+		Name string `db:"name"`
+	} `db:"details"`
+}
+
+func TestStructParametersWithTag(test *testing.T) {
+
+	var prsr Parser
+	var taggedParam TaggedParameterTest
+
+	taggedParam.Foo = "foo"
+	taggedParam.Bar = "bar"
+	taggedParam.Baz = "baz"
+	taggedParam.Details.Name = "name"
+
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :foo AND col2 = :Bar AND col3 = :Baz AND col4 = :details.name AND col5 = :other")
+	prsr.SetValuesFromStructWithTag(taggedParam, "db")
+
+	verifyStructParameters("StructWithTag", test, prsr, []interface{}{
+		"foo",
+		nil,
+		"baz",
+		"name",
+		nil,
+	})
+
+	prsr.ResetValues()
+	prsr.SetValuesFromStruct(taggedParam)
+
+	verifyStructParameters("StructWithDefaultTag", test, prsr, []interface{}{
+		nil,
+		"bar",
+		"baz",
+		nil,
+		"foo",
+	})
+}
+
+type TimeParameterTest struct {
+	Day      time.Time `sqlTimeFormat:"2006-01-02"`
+	Stamp    time.Time
+	Optional *time.Time `sqlTimeFormat:"15:04"`
+	Missing  *time.Time `sqlTimeFormat:"15:04"`
+}
+
+func TestTimeStructParameters(test *testing.T) {
+
+	var prsr Parser
+	var timeParam TimeParameterTest
+
+	moment := time.Date(2016, time.March, 14, 15, 9, 26, 0, time.UTC)
+
+	timeParam.Day = moment
+	timeParam.Stamp = moment
+	timeParam.Optional = &moment
+
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :Day AND col2 = :Stamp AND col3 = :Optional AND col4 = :Missing")
+	prsr.SetValuesFromStruct(timeParam)
+
+	verifyStructParameters("TimeStructReplacement", test, prsr, []interface{}{
+		"2016-03-14",
+		moment,
+		"15:09",
+		nil,
+	})
+}
+
+type SourceParameterTest struct {
+	First    string
+	Last     string
+	FullName string `sqlParameterSource:"Name" sqlParameterName:"name"`
+	Initials string `sqlParameterSource:"Abbreviate"`
+}
+
+func (s SourceParameterTest) Name() string {
+	return s.First + " " + s.Last
+}
+
+func (s *SourceParameterTest) Abbreviate() string {
+	return s.First[:1] + s.Last[:1]
+}
+
+type InvalidSourceParameterTest struct {
+	Missing string `sqlParameterSource:"Absent"`
+}
+
+type ArgumentSourceParameterTest struct {
+	Name string `sqlParameterSource:"Format"`
+}
+
+func (a ArgumentSourceParameterTest) Format(separator string) string {
+	return separator
+}
+
+type ResultsSourceParameterTest struct {
+	Name string `sqlParameterSource:"Lookup"`
+}
+
+func (r ResultsSourceParameterTest) Lookup() (string, error) {
+	return "", nil
+}
+
+func TestSourceStructParameters(test *testing.T) {
+
+	var prsr Parser
+	var sourceParam SourceParameterTest
+
+	sourceParam = SourceParameterTest{First: "Ada", Last: "Lovelace", FullName: "ignored"}
+
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :name AND col2 = :Initials AND col3 = :First")
+	prsr.SetValuesFromStruct(&sourceParam)
+
+	verifyStructParameters("SourceStructReplacement", test, prsr, []interface{}{
+		"Ada Lovelace",
+		"AL",
+		"Ada",
+	})
+
+	// a method with a pointer receiver cannot be called on a struct value.
+	if prsr.SetValuesFromStruct(sourceParam) == nil {
+		test.Log("Expected an error for a pointer receiver method of a struct value")
+		test.Fail()
+	}
+
+	invalidParams := map[string]interface{}{
+		"Missing":   InvalidSourceParameterTest{},
+		"Arguments": ArgumentSourceParameterTest{},
+		"Results":   ResultsSourceParameterTest{},
+	}
+
+	for name, invalidParam := range invalidParams {
+
+		prsr = NewParser("SELECT * FROM table WHERE col1 = :Name")
+
+		if prsr.SetValuesFromStruct(invalidParam) == nil {
+			test.Log("Test '", name, "': Expected an error for an invalid sqlParameterSource method")
+			test.Fail()
+		}
+	}
+}
+
+func TestBuild(test *testing.T) {
+
+	var prsr Parser
+	var query string
+	var parameters []interface{}
+
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :foo AND col2 IN (:ids)")
+	prsr.SetValue("foo", "bar")
+	prsr.SetValue("ids", []int{1, 2})
+
+	query, parameters = prsr.Build()
+
+	if query != prsr.GetParsedQuery() {
+		test.Log("Expected Build to return the parsed query. Actual: ", query)
+		test.Fail()
+	}
+
+	if len(parameters) != 3 || parameters[0] != "bar" || parameters[1] != 1 || parameters[2] != 2 {
+		test.Log("Expected Build to return the parsed parameters. Actual: ", parameters)
+		test.Fail()
+	}
+
+	if prsr.SQL() != query || len(prsr.Args()) != len(parameters) {
+		test.Log("Expected SQL and Args to match Build. Actual: ", prsr.SQL(), prsr.Args())
+		test.Fail()
+	}
+}
+
+type NestedNameParameterTest struct {
+	Name string `sqlParameterName:"name"`
+	Role *Period
+}
+
+type NestedParameterTest struct {
+	User    NestedNameParameterTest `sqlParameterName:"user"`
+	Manager *NestedNameParameterTest
+	Absent  *NestedNameParameterTest
+}
+
+func TestNestedStructParameters(test *testing.T) {
+
+	var prsr Parser
+	var nestedParam NestedParameterTest
+
+	nestedParam.User.Name = "alice"
+	nestedParam.User.Role = &Period{1, 2}
+	nestedParam.Manager = &NestedNameParameterTest{Name: "bob"}
+
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :user.name AND col2 = :Manager.name AND col3 = :user.Role.End AND col4 = :Absent.name AND col5 = :user.")
+	prsr.SetValuesFromStruct(nestedParam)
+
+	if prsr.GetParsedQuery() != "SELECT * FROM table WHERE col1 = $1 AND col2 = $2 AND col3 = $3 AND col4 = $4 AND col5 = $5." {
+		test.Log("Expected dotted parameter names to be parsed. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+
+	verifyStructParameters("NestedStructReplacement", test, prsr, []interface{}{
+		"alice",
+		"bob",
+		2,
+		nil,
+		nestedParam.User,
+	})
+}
+
+func TestReparse(test *testing.T) {
+
+	var prsr Parser
+	var clone Parser
+
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :foo AND col2 = :bar")
+	prsr.SetValue("foo", "foo")
+	prsr.Reparse("UPDATE table SET col1 = :baz WHERE col2 = :foo")
+
+	if prsr.GetParsedQuery() != "UPDATE table SET col1 = $1 WHERE col2 = $2" {
+		test.Log("Expected the new query to be parsed. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+
+	if prsr.GetOriginalQuery() != "UPDATE table SET col1 = :baz WHERE col2 = :foo" {
+		test.Log("Expected the original query to be replaced. Actual: ", prsr.GetOriginalQuery())
+		test.Fail()
+	}
+
+	if len(prsr.ParameterNames()) != 2 || prsr.ParameterNames()[0] != "baz" || prsr.ParameterNames()[1] != "foo" {
+		test.Log("Expected only the parameters of the new query. Actual: ", prsr.ParameterNames())
+		test.Fail()
+	}
+
+	if prsr.Validate() == nil {
+		test.Log("Expected every parameter to be unset after Reparse")
+		test.Fail()
+	}
+
+	// reparsing must not affect clones sharing the previous query.
+	clone = prsr.Clone()
+	prsr.Reparse("SELECT :other")
+
+	if clone.GetParsedQuery() != "UPDATE table SET col1 = $1 WHERE col2 = $2" || len(clone.ParameterNames()) != 2 {
+		test.Log("Expected a clone to keep its query after the original is reparsed. Actual: ", clone.GetParsedQuery())
+		test.Fail()
+	}
+
+	clone.SetValue("foo", "bar")
+
+	verifyStructParameters("CloneAfterReparse", test, clone, []interface{}{
+		nil,
+		"bar",
+	})
+
+	if prsr.GetParsedQuery() != "SELECT $1" {
+		test.Log("Expected the reparsed query. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+}
+
+func TestParameterCount(test *testing.T) {
+
+	var prsr Parser
+
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :foo AND col2 = :bar AND col3 = :foo AND col4 = ':foo'")
+	expected := map[string]int{
+		"foo":     2,
+		"bar":     1,
+		"unknown": 0,
+	}
+
+	for name, count := range expected {
+		if prsr.ParameterCount(name) != count {
+			test.Log("Expected parameter '", name, "' to occur ", count, " times. Actual: ", prsr.ParameterCount(name))
+			test.Fail()
+		}
+	}
+}
+
+func TestHasParameter(test *testing.T) {
+
+	var prsr Parser
+
+	prsr = NewParserWithOptions("SELECT * FROM table WHERE col1 = :Foo AND col2 = ':bar'", WithCaseInsensitiveNames())
+
+	if !prsr.HasParameter("foo") || !prsr.HasParameter("FOO") || prsr.HasParameter("bar") {
+		test.Log("Expected only the parameters of the query. Actual: ", prsr.ParameterNames())
+		test.Fail()
+	}
+}
+
+func TestNumParameters(test *testing.T) {
+
+	var prsr Parser
+
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :foo AND col2 IN (:ids) AND col3 = :foo AND col4 = ':bar'")
+
+	if prsr.NumParameters() != 3 {
+		test.Log("Expected a parameter for every occurrence. Actual: ", prsr.NumParameters())
+		test.Fail()
+	}
+
+	prsr.SetValue("ids", []int{1, 2, 3})
+
+	if prsr.NumParameters() != 5 || prsr.NumParameters() != len(prsr.GetParsedParameters()) {
+		test.Log("Expected expanded values to be counted. Actual: ", prsr.NumParameters())
+		test.Fail()
+	}
+}
+
+func TestSetValueStrict(test *testing.T) {
+
+	var prsr Parser
+	var err error
+
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :email")
+	err = prsr.SetValueStrict("emial", "alice@example.com")
+
+	if err == nil || err.Error() != "Unable to set value: query has no parameter :emial" {
+		test.Log("Expected an error for an unknown parameter. Actual: ", err)
+		test.Fail()
+	}
+
+	err = prsr.SetValueStrict("email", "alice@example.com")
+
+	if err != nil {
+		test.Log("Expected no error for a known parameter. Actual: ", err)
+		test.Fail()
+	}
+
+	verifyStructParameters("SetValueStrict", test, prsr, []interface{}{
+		"alice@example.com",
+	})
+}
+
+// JSON operators must be kept as literal text next to named parameters.
+func TestJSONOperators(test *testing.T) {
+
+	var prsr Parser
+
+	QueryParsingTests := []QueryParsingTest{
+		QueryParsingTest{
+			Input:              "SELECT data->'key', data->>'name' FROM table WHERE id = :id",
+			Expected:           "SELECT data->'key', data->>'name' FROM table WHERE id = $1",
+			ExpectedParameters: 1,
+			Name:               "ArrowOperators",
+		},
+		QueryParsingTest{
+			Input:              "SELECT data->>:field, data #> :path, data #>> :path FROM table",
+			Expected:           "SELECT data->>$1, data #> $2, data #>> $3 FROM table",
+			ExpectedParameters: 3,
+			Name:               "ArrowOperatorsWithParameters",
+		},
+		QueryParsingTest{
+			Input:              "SELECT * FROM table WHERE jsonb_col ?& array['a', 'b'] AND id = :id",
+			Expected:           "SELECT * FROM table WHERE jsonb_col ?& array['a', 'b'] AND id = $1",
+			ExpectedParameters: 1,
+			Name:               "ExistenceOperators",
+		},
+		QueryParsingTest{
+			Input:              "SELECT * FROM table WHERE jsonb_col ? 'a' AND jsonb_col ?| array[:a, :b] AND jsonb_col @> :doc::jsonb",
+			Expected:           "SELECT * FROM table WHERE jsonb_col ? 'a' AND jsonb_col ?| array[$1, $2] AND jsonb_col @> $3::jsonb",
+			ExpectedParameters: 3,
+			Name:               "ContainmentOperators",
+		},
+		QueryParsingTest{
+			Input:              "SELECT data #- '{a,b}' FROM table WHERE data->:key->>'x' = :value",
+			Expected:           "SELECT data #- '{a,b}' FROM table WHERE data->$1->>'x' = $2",
+			ExpectedParameters: 2,
+			Name:               "DeletePathOperator",
+		},
+	}
+
+	for _, parsingTest := range QueryParsingTests {
+
+		prsr = NewParser(parsingTest.Input)
+
+		if prsr.GetParsedQuery() != parsingTest.Expected {
+			test.Log("Test '", parsingTest.Name, "': Expected prsr text did not match actual parsed output")
+			test.Log("Actual: ", prsr.GetParsedQuery())
+			test.Fail()
+		}
+
+		if len(prsr.GetParsedParameters()) != parsingTest.ExpectedParameters {
+			test.Log("Test '", parsingTest.Name, "': Expected parameters did not match actual parsed parameter count")
+			test.Fail()
+		}
+	}
+}
+
+func TestSetPositional(test *testing.T) {
+
+	var prsr Parser
+	var err error
+
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :foo AND col2 = :bar AND col3 = :foo")
+	err = prsr.SetPositional("a", "b")
+
+	if err != nil {
+		test.Log("Expected no error when setting fewer values than positions. Actual: ", err)
+		test.Fail()
+	}
+
+	verifyStructParameters("PartialPositional", test, prsr, []interface{}{
+		"a",
+		"b",
+		nil,
+	})
+
+	err = prsr.SetPositional(1, 2, 3, 4)
+
+	if err == nil {
+		test.Log("Expected an error when setting more values than positions")
+		test.Fail()
+	}
+
+	verifyStructParameters("TooManyPositional", test, prsr, []interface{}{
+		"a",
+		"b",
+		nil,
+	})
+
+	prsr.SetPositional(1, 2, 3)
+
+	verifyStructParameters("AllPositional", test, prsr, []interface{}{
+		1,
+		2,
+		3,
+	})
+}
+
+func TestNewParserWithMap(test *testing.T) {
+
+	var prsr Parser
+
+	prsr = NewParserWithMap("SELECT * FROM table WHERE col1 = :foo AND col2 = :bar", map[string]interface{}{
+		"foo":    "something",
+		"bar":    "else",
+		"unused": 1,
+	})
+
+	verifyStructParameters("NewParserWithMap", test, prsr, []interface{}{
+		"something",
+		"else",
+	})
+}
+
+func TestPositions(test *testing.T) {
+
+	var prsr Parser
+
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :foo AND col2 IN (:ids) AND col3 = :foo")
+
+	verifyPositions("Unexpanded", test, prsr.Positions("foo"), []int{1, 3})
+
+	prsr.SetValue("ids", []int{1, 2, 3})
+
+	verifyPositions("Expanded", test, prsr.Positions("ids"), []int{2, 3, 4})
+	verifyPositions("AfterExpansion", test, prsr.Positions("foo"), []int{1, 5})
+
+	if prsr.Positions("unknown") != nil {
+		test.Log("Expected no positions for an unknown parameter")
+		test.Fail()
+	}
+
+	// the returned slice must be a copy.
+	prsr.Positions("foo")[0] = 10
+	verifyPositions("Copy", test, prsr.Positions("foo"), []int{1, 5})
+}
+
+func verifyPositions(testName string, test *testing.T, actualPositions []int, expectedPositions []int) {
+
+	if len(actualPositions) != len(expectedPositions) {
+		test.Log("Test ", testName, ": Actual positions ", actualPositions, " did not match expected positions ", expectedPositions)
+		test.Fail()
+		return
+	}
+
+	for index, position := range actualPositions {
+		if position != expectedPositions[index] {
+			test.Log("Test ", testName, ": Actual positions ", actualPositions, " did not match expected positions ", expectedPositions)
+			test.Fail()
+			return
+		}
+	}
+}
+
+func TestSetValuesFromMaps(test *testing.T) {
+
+	var prsr Parser
+
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :foo AND col2 = :bar AND col3 = :baz")
+	prsr.SetValuesFromMaps(
+		map[string]interface{}{
+			"foo": "default",
+			"bar": "default",
+			"baz": "default",
+		},
+		map[string]interface{}{
+			"bar": "body",
+			"baz": "body",
+		},
+		nil,
+		map[string]interface{}{
+			"baz": "override",
+		},
+	)
+
+	verifyStructParameters("MapOverridePrecedence", test, prsr, []interface{}{
+		"default",
+		"body",
+		"override",
+	})
+}
+
+func TestUnicodeParameterNames(test *testing.T) {
+
+	var prsr Parser
+
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :naïve AND col2 = :名前 AND col3 = :nai\u0308ve AND col4 = :ü")
+	prsr.SetValue("naïve", "composed")
+	prsr.SetValue("名前", "cjk")
+	prsr.SetValue("nai\u0308ve", "decomposed")
+	prsr.SetValue("ü", "single")
+
+	if prsr.GetParsedQuery() != "SELECT * FROM table WHERE col1 = $1 AND col2 = $2 AND col3 = $3 AND col4 = $4" {
+		test.Log("Expected unicode parameter names to be captured in full. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+
+	verifyStructParameters("UnicodeParameterNames", test, prsr, []interface{}{
+		"composed",
+		"cjk",
+		"decomposed",
+		"single",
+	})
+
+	// text which is not valid UTF-8 must be copied unchanged.
+	prsr = NewParser("SELECT * FROM table WHERE col1 = 'caf\xe9' AND col2 = :foo -- \xff")
+
+	if prsr.GetParsedQuery() != "SELECT * FROM table WHERE col1 = 'caf\xe9' AND col2 = $1 -- \xff" {
+		test.Log("Expected invalid UTF-8 to be copied unchanged. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+}
+
+// A parameter ending the query must be captured without appending anything after it.
+func TestParameterAtEndOfQuery(test *testing.T) {
+
+	var prsr Parser
+
+	for _, driver := range []Driver{DriverPostgres, DriverMySQL, DriverSQLServer, DriverOracle} {
+
+		prsr = NewParserForDriver("SELECT * FROM t WHERE id = :id", driver)
+		prsr.SetValue("id", 5)
+
+		expected := "SELECT * FROM t WHERE id = " + driver.placeholder(1)
+
+		if prsr.GetParsedQuery() != expected {
+			test.Log("Driver ", driver, ": Expected '", expected, "'. Actual: '", prsr.GetParsedQuery(), "'")
+			test.Fail()
+		}
+
+		verifyStructParameters("ParameterAtEndOfQuery", test, prsr, []interface{}{
+			5,
+		})
+	}
+
+	_, err := NewParserStrict("SELECT * FROM t WHERE id = :id")
+
+	if err != nil {
+		test.Log("Expected a parameter at the end of a query to be well formed. Actual: ", err)
+		test.Fail()
+	}
+}
+
+func TestSetDefault(test *testing.T) {
+
+	var prsr Parser
+	var clone Parser
+
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :foo AND col2 = :status AND col3 = :status")
+	prsr.SetValue("status", "explicit")
+	prsr.SetDefault("status", "")
+	prsr.SetDefault("foo", "default")
+
+	verifyStructParameters("ExplicitValueWins", test, prsr, []interface{}{
+		"default",
+		"explicit",
+		"explicit",
+	})
+
+	if prsr.Validate() != nil {
+		test.Log("Expected parameters with defaults not to be reported as missing. Actual: ", prsr.Validate())
+		test.Fail()
+	}
+
+	prsr.SetValue("foo", "explicit")
+	prsr.ResetValues()
+
+	verifyStructParameters("DefaultsAfterReset", test, prsr, []interface{}{
+		"default",
+		"",
+		"",
+	})
+
+	clone = prsr.Clone()
+	clone.SetDefault("foo", "clone")
+
+	verifyStructParameters("DefaultsInClone", test, clone, []interface{}{
+		"clone",
+		"",
+		"",
+	})
+
+	verifyStructParameters("CloneDefaultsIsolated", test, prsr, []interface{}{
+		"default",
+		"",
+		"",
+	})
+}
+
+func TestString(test *testing.T) {
+
+	var prsr Parser
+
+	prsr = NewParserWithOptions("SELECT * FROM table WHERE col1 = :foo AND col2 IN (:ids) AND col3 = ? AND col4 = :foo AND col5 = :bar", WithPositionalPassthrough())
+	prsr.SetValue("foo", "bar")
+	prsr.SetValue("ids", []int{1, 2})
+	prsr.SetValue("bar", nil)
+
+	expected := "SELECT * FROM table WHERE col1 = $1 AND col2 IN ($2, $3) AND col3 = $4 AND col4 = $5 AND col5 = $6\n" +
+		":foo = \"bar\"\n" +
+		":ids = []int{1, 2}\n" +
+		"?3 = <unset>\n" +
+		":bar = <nil>"
+
+	if prsr.(fmt.Stringer).String() != expected {
+		test.Log("Unexpected debug output. Actual: ", prsr.(fmt.Stringer).String())
+		test.Fail()
+	}
+}
+
+func TestMustParse(test *testing.T) {
+
+	var prsr Parser
+
+	prsr = MustParse("SELECT * FROM table WHERE col1 = :foo")
+
+	if prsr.GetParsedQuery() != "SELECT * FROM table WHERE col1 = $1" {
+		test.Log("Unexpected parsed output: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+
+	defer func() {
+		if recover() == nil {
+			test.Log("Expected MustParse to panic for a malformed query")
+			test.Fail()
+		}
+	}()
+
+	MustParse("SELECT * FROM table WHERE col1 = 'foo")
+}
+
+func TestNestedMapParameters(test *testing.T) {
+
+	var prsr Parser
+
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :user.name AND col2 = :user.address.city AND col3 = :user.missing AND col4 = :other.name AND col5 = :id")
+	prsr.SetValuesFromMap(map[string]interface{}{
+		"user": map[string]interface{}{
+			"name": "alice",
+			"address": map[string]interface{}{
+				"city": "Springfield",
+			},
+		},
+		"other": "not a map",
+		"id":    5,
+	})
+
+	verifyStructParameters("NestedMapReplacement", test, prsr, []interface{}{
+		"alice",
+		"Springfield",
+		nil,
+		nil,
+		5,
+	})
+
+	if prsr.Validate() == nil || prsr.Validate().Error() != "Missing values for parameters :user.missing, :other.name" {
+		test.Log("Expected unresolved nested names to remain unset. Actual: ", prsr.Validate())
+		test.Fail()
+	}
+}
+
+func TestSetValuesFromNamedArgs(test *testing.T) {
+
+	var prsr Parser
+
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :foo AND col2 = :bar AND col3 = :foo")
+	prsr.SetValuesFromNamedArgs(sql.Named("foo", "something"), sql.Named("bar", 2), sql.Named("unused", 3))
+
+	verifyStructParameters("NamedArgs", test, prsr, []interface{}{
+		"something",
+		2,
+		"something",
+	})
+}
+
+func TestEscapedPrefix(test *testing.T) {
+
+	var prsr Parser
+
+	prsr = NewParser(`SELECT * FROM table WHERE col1 = some_function(\:foo) AND col2 = :foo AND col3 = '\:bar'`)
+
+	if prsr.GetParsedQuery() != `SELECT * FROM table WHERE col1 = some_function(:foo) AND col2 = $1 AND col3 = '\:bar'` {
+		test.Log("Expected an escaped colon to become a literal colon. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+
+	if len(prsr.ParameterNames()) != 1 || prsr.ParameterCount("foo") != 1 {
+		test.Log("Expected an escaped parameter not to be registered. Actual: ", prsr.ParameterNames())
+		test.Fail()
+	}
+
+	prsr = NewParserWithOptions(`SELECT \@foo, @foo`, WithPrefix('@'))
+
+	if prsr.GetParsedQuery() != `SELECT @foo, $1` {
+		test.Log("Expected escaping to apply to the configured prefix. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+}
+
+func TestManyParameters(test *testing.T) {
+
+	var prsr Parser
+
+	prsr = NewParser("INSERT INTO table VALUES (:a, :b, :c, :d, :e, :f, :g, :h, :i, :j, :a, :j)")
+	prsr.SetValue("a", 1)
+	prsr.SetValue("j", 10)
+
+	if len(prsr.ParameterNames()) != 10 || prsr.ParameterNames()[9] != "j" {
+		test.Log("Expected every distinct parameter in order of appearance. Actual: ", prsr.ParameterNames())
+		test.Fail()
+	}
+
+	if prsr.ParameterCount("a") != 2 || prsr.ParameterCount("j") != 2 || prsr.ParameterCount("k") != 0 {
+		test.Log("Expected repeated parameters to be counted once per occurrence")
+		test.Fail()
+	}
+
+	verifyStructParameters("ManyParameters", test, prsr, []interface{}{
+		1, nil, nil, nil, nil, nil, nil, nil, nil, 10, 1, 10,
+	})
+
+	prsr.Reparse("SELECT :z, :a")
+	prsr.SetValue("a", 1)
+
+	verifyStructParameters("ManyParametersReparsed", test, prsr, []interface{}{
+		nil,
+		1,
+	})
+}
+
+func TestSetValuesFromURLValues(test *testing.T) {
+
+	var prsr Parser
+	var values url.Values
+
+	values, _ = url.ParseQuery("name=foo&name=bar&ids=1&ids=2&unused=baz")
+
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :name AND col2 IN (:ids) AND col3 = :missing")
+	prsr.SetValuesFromURLValues(values, "ids")
+
+	if prsr.GetParsedQuery() != "SELECT * FROM table WHERE col1 = $1 AND col2 IN ($2, $3) AND col3 = $4" {
+		test.Log("Expected a slice name to be expanded. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+
+	verifyStructParameters("URLValues", test, prsr, []interface{}{
+		"foo",
+		"1",
+		"2",
+		nil,
+	})
+
+	if prsr.Validate() == nil {
+		test.Log("Expected a parameter missing from the values to remain unset")
+		test.Fail()
+	}
+
+	values, _ = url.ParseQuery("IDS=1&IDS=2")
+
+	prsr = NewParserWithOptions("SELECT * FROM table WHERE col1 IN (:ids)", WithCaseInsensitiveNames())
+	prsr.SetValuesFromURLValues(values, "ids")
+
+	verifyStructParameters("CaseInsensitiveURLValues", test, prsr, []interface{}{
+		"1",
+		"2",
+	})
+}
+
+func TestQuotedParameterLikeTokens(test *testing.T) {
+
+	var prsr Parser
+	var tokens []string
+
+	prsr = NewParser(`SELECT 'hello :name', ':name :other', '12:30', '::text', '\:escaped', "col:ident", :name`)
+	tokens = prsr.QuotedParameterLikeTokens()
+
+	if len(tokens) != 2 || tokens[0] != ":name" || tokens[1] != ":other" {
+		test.Log("Expected the distinct parameter-like tokens inside string literals. Actual: ", tokens)
+		test.Fail()
+	}
+
+	if prsr.GetParsedQuery() != `SELECT 'hello :name', ':name :other', '12:30', '::text', '\:escaped', "col:ident", $1` {
+		test.Log("Expected quoted tokens not to affect parsing. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+
+	prsr.Reparse("SELECT :name")
+
+	if prsr.QuotedParameterLikeTokens() != nil {
+		test.Log("Expected no quoted tokens after Reparse. Actual: ", prsr.QuotedParameterLikeTokens())
+		test.Fail()
+	}
+}
+
+func TestGetParameterMap(test *testing.T) {
+
+	var prsr Parser
+	var parameters map[string]interface{}
+
+	prsr = NewParserWithOptions("SELECT * FROM table WHERE col1 = :id AND col2 = :status AND col3 = ? AND col4 = :id", WithPositionalPassthrough())
+	prsr.SetValue("id", 5)
+	parameters = prsr.GetParameterMap()
+
+	if len(parameters) != 3 || parameters["id"] != 5 || parameters["status"] != nil || parameters["?3"] != nil {
+		test.Log("Expected every distinct parameter with its value. Actual: ", parameters)
+		test.Fail()
+	}
+
+	if _, exists := parameters["status"]; !exists {
+		test.Log("Expected an unset parameter to map to nil. Actual: ", parameters)
+		test.Fail()
+	}
+}
+
+func TestDollarQuotedStrings(test *testing.T) {
+
+	var prsr Parser
+
+	prsr = NewParser("CREATE FUNCTION foo() RETURNS int AS $$ SELECT :notaparam $$ LANGUAGE sql; SELECT :foo, $body$ it's :other $one$ $body$, $1")
+
+	if prsr.GetParsedQuery() != "CREATE FUNCTION foo() RETURNS int AS $$ SELECT :notaparam $$ LANGUAGE sql; SELECT $1, $body$ it's :other $one$ $body$, $1" {
+		test.Log("Expected dollar quoted strings to be copied verbatim. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+
+	if len(prsr.ParameterNames()) != 1 || prsr.ParameterNames()[0] != "foo" {
+		test.Log("Expected no parameters inside dollar quoted strings. Actual: ", prsr.ParameterNames())
+		test.Fail()
+	}
+
+	// dollar quoting is specific to Postgres.
+	prsr = NewParserForDriver("SELECT $$ :foo $$", DriverMySQL)
+
+	if prsr.GetParsedQuery() != "SELECT $$ ? $$" {
+		test.Log("Expected dollar quotes to be ignored for other drivers. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+}
+
+func TestDigitParameterNames(test *testing.T) {
+
+	var prsr Parser
+
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :2 AND col2 = :1 AND col3 = :2")
+	prsr.SetValue("1", "one")
+	prsr.SetValue("2", "two")
+
+	if prsr.GetParsedQuery() != "SELECT * FROM table WHERE col1 = $1 AND col2 = $2 AND col3 = $3" {
+		test.Log("Expected digit names to be parsed as named parameters. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+
+	verifyStructParameters("DigitNames", test, prsr, []interface{}{
+		"two",
+		"one",
+		"two",
+	})
+
+	// Oracle bind variables in the revised query are numbered by position, not by name.
+	prsr = NewParserForDriver("SELECT * FROM table WHERE col1 = :2 AND col2 = :1", DriverOracle)
+	prsr.SetValue("1", "one")
+	prsr.SetValue("2", "two")
+
+	if prsr.GetParsedQuery() != "SELECT * FROM table WHERE col1 = :1 AND col2 = :2" {
+		test.Log("Expected digit names to be renumbered for Oracle. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+
+	verifyStructParameters("OracleDigitNames", test, prsr, []interface{}{
+		"two",
+		"one",
+	})
+}
+
+func TestSetValuesFromMapReport(test *testing.T) {
+
+	var prsr Parser
+	var unmatched []string
+
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :userId AND col2 = :user.name AND col3 = :status")
+	unmatched = prsr.SetValuesFromMapReport(map[string]interface{}{
+		"user_id": 5,
+		"status":  "active",
+		"user": map[string]interface{}{
+			"name": "foo",
+			"nmae": "foo",
+		},
+	})
+
+	if len(unmatched) != 2 || unmatched[0] != "user.nmae" || unmatched[1] != "user_id" {
+		test.Log("Expected the sorted keys which did not match any parameter. Actual: ", unmatched)
+		test.Fail()
+	}
+
+	verifyStructParameters("MapReport", test, prsr, []interface{}{
+		nil,
+		"foo",
+		"active",
+	})
+
+	unmatched = prsr.SetValuesFromMapReport(map[string]interface{}{
+		"userId": 5,
+	})
+
+	if unmatched != nil {
+		test.Log("Expected no unmatched keys. Actual: ", unmatched)
+		test.Fail()
+	}
+}
+
+func TestSetValuesFromMapStrict(test *testing.T) {
+
+	var prsr Parser
+	var err error
+
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :userId AND col2 = :user.name AND col3 = :status")
+	err = prsr.SetValuesFromMapStrict(map[string]interface{}{
+		"user_id": 5,
+		"status":  "active",
+		"user": map[string]interface{}{
+			"name": "foo",
+			"nmae": "foo",
+		},
+	})
+
+	if err == nil || err.Error() != "Unable to set values: query has no parameters :user.nmae, :user_id" {
+		test.Log("Expected an error naming the unmatched keys. Actual: ", err)
+		test.Fail()
+	}
+
+	verifyStructParameters("MapStrictUnmatched", test, prsr, []interface{}{
+		nil,
+		nil,
+		nil,
+	})
+
+	err = prsr.SetValuesFromMapStrict(map[string]interface{}{
+		"userId": 5,
+		"status": "active",
+	})
+
+	if err != nil {
+		test.Log("Expected no error if every key matches. Actual: ", err)
+		test.Fail()
+	}
+
+	verifyStructParameters("MapStrict", test, prsr, []interface{}{
+		5,
+		nil,
+		"active",
+	})
+}
+
+func TestGetParsedParametersForNames(test *testing.T) {
+
+	var prsr Parser
+	var parameters []interface{}
+
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :foo AND col2 IN (:ids) AND col3 = :bar AND col4 = :foo")
+	prsr.SetValue("foo", "foo")
+	prsr.SetValue("bar", "bar")
+	prsr.SetValue("ids", []int{1, 2})
+
+	parameters = prsr.GetParsedParametersForNames("foo", "ids", "unknown")
+
+	if len(parameters) != 4 || parameters[0] != "foo" || parameters[1] != 1 || parameters[2] != 2 || parameters[3] != "foo" {
+		test.Log("Expected the values of the given names in positional order. Actual: ", parameters)
+		test.Fail()
+	}
+
+	if prsr.GetParsedParametersForNames("unknown") != nil {
+		test.Log("Expected no values for unknown names")
+		test.Fail()
+	}
+}
+
+func TestSetValueAt(test *testing.T) {
+
+	var prsr Parser
+
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :foo AND col2 = :bar AND col3 = :foo")
+	prsr.SetValue("foo", "foo")
+
+	if prsr.SetValueAt(2, "baz") != nil {
+		test.Log("Expected a position in range to be set")
+		test.Fail()
+	}
+
+	if prsr.SetValueAt(3, "baz") == nil || prsr.SetValueAt(-1, "baz") == nil {
+		test.Log("Expected an error for a position out of range")
+		test.Fail()
+	}
+
+	verifyStructParameters("SetValueAt", test, prsr, []interface{}{
+		"foo",
+		nil,
+		"baz",
+	})
+}
+
+func TestSetValueAtOccurrence(test *testing.T) {
+
+	var prsr Parser
+
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :foo AND col2 = :bar AND col3 = :foo")
+	prsr.SetValue("foo", "foo")
+
+	if prsr.SetValueAtOccurrence("foo", 1, "baz") != nil {
+		test.Log("Expected an occurrence in range to be set")
+		test.Fail()
+	}
+
+	if prsr.SetValueAtOccurrence("foo", 2, "baz") == nil || prsr.SetValueAtOccurrence("foo", -1, "baz") == nil {
+		test.Log("Expected an error for an occurrence out of range")
+		test.Fail()
+	}
+
+	if prsr.SetValueAtOccurrence("missing", 0, "baz") == nil {
+		test.Log("Expected an error for a parameter which is not part of the query")
+		test.Fail()
+	}
+
+	verifyStructParameters("SetValueAtOccurrence", test, prsr, []interface{}{
+		"foo",
+		nil,
+		"baz",
+	})
+}
+
+func TestReset(test *testing.T) {
+
+	var prsr Parser
+
+	prsr = NewParserWithOptions("SELECT * FROM table WHERE col1 = @foo AND col2 = @bar", WithPrefix('@'))
+	prsr.SetValue("foo", "foo")
+	prsr.SetDefault("bar", "bar")
+	prsr.Reset()
+
+	if prsr.GetParsedQuery() != "" || len(prsr.GetParsedParameters()) != 0 || len(prsr.ParameterNames()) != 0 {
+		test.Log("Expected an empty query after Reset. Actual: ", prsr.GetParsedQuery(), prsr.GetParsedParameters())
+		test.Fail()
+	}
+
+	prsr.Reparse("SELECT @bar, @baz")
+	prsr.SetValue("baz", "baz")
+
+	if prsr.GetParsedQuery() != "SELECT $1, $2" {
+		test.Log("Expected a reset parser to keep its options. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+
+	verifyStructParameters("Reset", test, prsr, []interface{}{
+		nil,
+		"baz",
+	})
+}
+
+func TestParameterOffsets(test *testing.T) {
+
+	var prsr Parser
+	var offsets map[string][]int
+
+	prsr = NewParserWithOptions("SELECT :foo, ?, ':bar', :bär, :foo::int", WithPositionalPassthrough())
+	offsets = prsr.ParameterOffsets()
+
+	if len(offsets) != 2 || len(offsets["foo"]) != 2 || offsets["foo"][0] != 7 || offsets["foo"][1] != 31 {
+		test.Log("Expected the offsets of every occurrence. Actual: ", offsets)
+		test.Fail()
+	}
+
+	if len(offsets["bär"]) != 1 || offsets["bär"][0] != 24 {
+		test.Log("Expected byte offsets after multi-byte characters. Actual: ", offsets)
+		test.Fail()
+	}
+}
+
+func TestCRLFLineComments(test *testing.T) {
+
+	var prsr Parser
+
+	prsr = NewParser("SELECT * FROM table -- :commented\r\nWHERE col1 = :foo -- :other\r\n\tAND col2 = :bar\rAND col3 = :baz -- :last")
+
+	if prsr.GetParsedQuery() != "SELECT * FROM table -- :commented\r\nWHERE col1 = $1 -- :other\r\n\tAND col2 = $2\rAND col3 = $3 -- :last" {
+		test.Log("Expected CRLF line endings to end line comments. Actual: ", strconv.Quote(prsr.GetParsedQuery()))
+		test.Fail()
+	}
+
+	prsr = NewParser("SELECT :foo -- :commented\r:bar")
+
+	if len(prsr.ParameterNames()) != 2 || prsr.ParameterNames()[1] != "bar" {
+		test.Log("Expected a carriage return to end a line comment. Actual: ", prsr.ParameterNames())
+		test.Fail()
+	}
+}
+
+func TestSetValuesFromJSON(test *testing.T) {
+
+	var prsr Parser
+
+	prsr = NewParser("SELECT * FROM table WHERE col1 = :id AND col2 = :user.name AND col3 IN (:tags) AND col4 = :none")
+
+	if prsr.SetValuesFromJSON([]byte(`{"id": 5, "user": {"name": "foo"}, "tags": ["a", "b"], "none": null}`)) != nil {
+		test.Log("Expected a JSON object to be bound")
+		test.FailNow()
+	}
+
+	verifyStructParameters("JSON", test, prsr, []interface{}{
+		float64(5),
+		"foo",
+		"a",
+		"b",
+		nil,
+	})
+
+	if prsr.SetValuesFromJSON([]byte(`["id"]`)) == nil || prsr.SetValuesFromJSON([]byte(`{"id": `)) == nil || prsr.SetValuesFromJSON([]byte(`null`)) == nil {
+		test.Log("Expected an error for data which is not a JSON object")
+		test.Fail()
+	}
+}
@@ -0,0 +1,112 @@
+package npq
+
+// positionStoreThreshold is the number of distinct parameter names above which
+// a positionStore builds a map to look up names, instead of searching them.
+const positionStoreThreshold = 8
+
+// positionStore maps parameter names to the positional indices which match
+// them, keeping the names in the order they were first added.
+//
+// Most queries only have a handful of parameters, for which searching a slice
+// is cheaper than allocating and hashing into a map, so the map is only built
+// once the store holds more than positionStoreThreshold names.
+type positionStore struct {
+
+	// The distinct parameter names, in the order they were added.
+	names []string
+
+	// The positional indices of every name, in the same order as names.
+	indices [][]int
+
+	// Storage for the first positional index of every name.
+	backing []int
+
+	// The index of every name in names, or nil while there are few names.
+	lookup map[string]int
+}
+
+// find returns the index of the given [name] in s.names, or -1 if s does not
+// contain it.
+func (s *positionStore) find(name string) int {
+
+	if s.lookup != nil {
+
+		if slot, exists := s.lookup[name]; exists {
+			return slot
+		}
+		return -1
+	}
+
+	for slot, storedName := range s.names {
+		if storedName == name {
+			return slot
+		}
+	}
+	return -1
+}
+
+// get returns the positional indices of the given [name], or nil if s does not
+// contain it.
+func (s *positionStore) get(name string) []int {
+
+	var slot int
+
+	slot = s.find(name)
+
+	if slot < 0 {
+		return nil
+	}
+	return s.indices[slot]
+}
+
+// has returns true if s contains the given [name].
+func (s *positionStore) has(name string) bool {
+	return s.find(name) >= 0
+}
+
+// add appends the given [position] to the positional indices of [name], adding
+// the name if s does not contain it yet.
+func (s *positionStore) add(name string, position int) {
+
+	var slot int
+
+	slot = s.find(name)
+
+	if slot >= 0 {
+		s.indices[slot] = append(s.indices[slot], position)
+		return
+	}
+
+	slot = len(s.names)
+	s.names = append(s.names, name)
+
+	// the first index of every name is carved out of one shared backing slice, capped so
+	// that appending a repeated occurrence copies it out instead of overwriting its neighbour.
+	s.backing = append(s.backing, position)
+	s.indices = append(s.indices, s.backing[len(s.backing)-1:len(s.backing):len(s.backing)])
+
+	if s.lookup != nil {
+		s.lookup[name] = slot
+	} else if len(s.names) > positionStoreThreshold {
+
+		s.lookup = make(map[string]int, 2*len(s.names))
+
+		for storedSlot, storedName := range s.names {
+			s.lookup[storedName] = storedSlot
+		}
+	}
+}
+
+// len returns the number of distinct names in s.
+func (s *positionStore) len() int {
+	return len(s.names)
+}
+
+// reset removes every name from s, keeping its memory for reuse.
+func (s *positionStore) reset() {
+
+	s.names = s.names[:0]
+	s.indices = s.indices[:0]
+	s.backing = s.backing[:0]
+	s.lookup = nil
+}
@@ -0,0 +1,296 @@
+package npq
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+)
+
+// valuesTuplePattern matches the keyword and opening parenthesis of a VALUES tuple.
+var valuesTuplePattern = regexp.MustCompile(`(?i)\bVALUES\s*\(`)
+
+// nextTuplePattern matches the comma and opening parenthesis of the tuple following
+// another in a VALUES list.
+var nextTuplePattern = regexp.MustCompile(`^\s*,\s*\(`)
+
+// ExpandRows replicates the VALUES tuple of p query once for every element of
+// the given [rows], and binds the parameters of each copy from its element, so
+// that a single row insert becomes a bulk insert, e.g.
+//
+// 	INSERT INTO table (a, b) VALUES (:a, :b)
+//
+// becomes, for two rows,
+//
+// 	INSERT INTO table (a, b) VALUES ($1, $2), ($3, $4)
+//
+// [rows] must be a non-empty slice or array of structs, pointers to structs, or
+// map[string]interface{} values. Struct fields are bound in the same way as by
+// SetValuesFromStruct, and map keys in the same way as by SetValuesFromMap.
+// The first VALUES tuple of the query which contains a parameter is expanded.
+//
+// ExpandRows parses the original query again, so every value previously set is
+// removed; parameters outside of the tuple should be set afterwards. It cannot
+// be used together with WithDeduplication, nor with optional clauses.
+func (p *parser) ExpandRows(rows interface{}) error {
+
+	var rowValues reflect.Value
+	var rowMaps []map[string]interface{}
+	var err error
+
+	rowValues = reflect.ValueOf(rows)
+
+	if rowValues.Kind() != reflect.Slice && rowValues.Kind() != reflect.Array {
+		return errors.New("Unable to expand rows: rows is not a slice")
+	}
+
+	if rowValues.Len() <= 0 {
+		return errors.New("Unable to expand rows: rows is empty")
+	}
+
+	if p.deduplicates() {
+		return errors.New("Unable to expand rows: parameters are deduplicated")
+	}
+
+	if len(p.clauses) > 0 {
+		return errors.New("Unable to expand rows: query has optional clauses")
+	}
+
+	if p.streamed {
+		return errors.New("Unable to expand rows: query was parsed from a stream")
+	}
+
+	p.Reparse(p.originalQuery)
+
+	rowMaps = make([]map[string]interface{}, rowValues.Len())
+
+	for i := range rowMaps {
+
+		rowMaps[i], err = p.rowValues(rowValues.Index(i))
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return p.replicateTuple(rowMaps)
+}
+
+// rowValues returns the parameter values of the given [row] element, keyed by
+// their normalized names.
+func (p *parser) rowValues(row reflect.Value) (map[string]interface{}, error) {
+
+	var values map[string]interface{}
+	var bindings []structBinding
+	var err error
+
+	for row.Kind() == reflect.Interface || row.Kind() == reflect.Ptr {
+
+		if row.IsNil() {
+			return nil, errors.New("Unable to expand rows: row is nil")
+		}
+		row = row.Elem()
+	}
+
+	if rowMap, isMap := row.Interface().(map[string]interface{}); isMap {
+
+		values = make(map[string]interface{}, len(rowMap))
+
+		for name, value := range rowMap {
+			values[p.normalizeName(name)] = value
+		}
+		return values, nil
+	}
+
+	if row.Kind() != reflect.Struct {
+		return nil, errors.New("Unable to expand rows: row is not a struct or map[string]interface{}")
+	}
+
+	bindings, err = p.collectStructValues(row, parameterNameTag, "", "", nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	values = make(map[string]interface{}, len(bindings))
+
+	for _, binding := range bindings {
+		values[p.normalizeName(binding.name)] = binding.value
+	}
+	return values, nil
+}
+
+// replicateTuple rebuilds the parsed query of p with its first VALUES tuple
+// repeated once for each of the given [rows], and binds every copy from the
+// values of its row.
+func (p *parser) replicateTuple(rows []map[string]interface{}) error {
+
+	var segments []string
+	var occurrences []int
+	var offsets []int
+	var positionNames []string
+	var first, last int
+	var opening, closing int
+	var width int
+	var shift int
+	var found bool
+	var next []int
+
+	for first = 0; first < len(p.occurrences) && !found; first++ {
+
+		for _, match := range valuesTuplePattern.FindAllStringIndex(p.segments[first], -1) {
+
+			opening = match[1] - 1
+
+			for {
+
+				last, closing, found = p.closeTuple(first, opening)
+
+				if found || last != first {
+					break
+				}
+
+				// a tuple without parameters may be followed by another, e.g. "VALUES (1), (:a)".
+				next = nextTuplePattern.FindStringIndex(p.segments[first][closing+1:])
+
+				if next == nil {
+					break
+				}
+				opening = closing + next[1]
+			}
+
+			if found {
+				break
+			}
+		}
+	}
+
+	if !found {
+		return errors.New("Unable to expand rows: query has no VALUES tuple containing parameters")
+	}
+	first--
+
+	// without deduplication, every occurrence has the next position.
+	width = last - first
+	shift = (len(rows) - 1) * width
+
+	segments = append(segments, p.segments[:first]...)
+	occurrences = append(occurrences, p.occurrences[:first]...)
+	offsets = append(offsets, p.offsets[:first]...)
+	positionNames = append(positionNames, p.positionNames[:p.occurrences[first]]...)
+
+	for row := range rows {
+
+		if row == 0 {
+			segments = append(segments, p.segments[first])
+		} else {
+			segments[len(segments)-1] += ", " + p.segments[first][opening:]
+		}
+
+		for occurrence := first; occurrence < last; occurrence++ {
+
+			if occurrence > first {
+				segments = append(segments, p.segments[occurrence])
+			}
+
+			occurrences = append(occurrences, p.occurrences[occurrence]+row*width)
+			offsets = append(offsets, p.offsets[occurrence])
+			positionNames = append(positionNames, p.positionNames[p.occurrences[occurrence]])
+		}
+
+		segments = append(segments, p.segments[last][:closing+1])
+	}
+
+	segments[len(segments)-1] += p.segments[last][closing+1:]
+	segments = append(segments, p.segments[last+1:]...)
+
+	for _, position := range p.occurrences[last:] {
+		occurrences = append(occurrences, position+shift)
+	}
+	offsets = append(offsets, p.offsets[last:]...)
+	positionNames = append(positionNames, p.positionNames[p.occurrences[first]+width:]...)
+
+	for i := range p.statements {
+		if p.statements[i] > p.occurrences[first] {
+			p.statements[i] += shift
+		}
+	}
+
+	p.segments = segments
+	p.occurrences = occurrences
+	p.offsets = offsets
+	p.positionNames = positionNames
+	p.positions.reset()
+
+	for position, name := range positionNames {
+		if len(name) > 0 {
+			p.positions.add(name, position)
+		}
+	}
+
+	p.parameters = make([]interface{}, len(positionNames))
+	p.assigned = make([]bool, len(positionNames))
+	p.expansions = make([][]interface{}, len(positionNames))
+	p.rendered = false
+
+	for row, values := range rows {
+
+		for position := p.occurrences[first] + row*width; position < p.occurrences[first]+(row+1)*width; position++ {
+
+			if value, exists := values[positionNames[position]]; exists {
+				p.setPosition(position, value)
+			}
+		}
+	}
+
+	p.applyDefaults()
+	return nil
+}
+
+// closeTuple finds the parenthesis closing the one at byte [opening] of the segment
+// before the given [first] occurrence, skipping quoted text. It returns the index
+// of the segment containing it, which is also the index of the first occurrence
+// after the tuple, and its byte offset in that segment. If the tuple does not
+// contain the first occurrence, or is never closed, found is false.
+func (p *parser) closeTuple(first int, opening int) (last int, closing int, found bool) {
+
+	var depth int
+	var quote byte
+	var segment string
+
+	depth = 0
+
+	for last = first; last < len(p.segments); last++ {
+
+		segment = p.segments[last]
+		closing = 0
+
+		if last == first {
+			closing = opening
+		}
+
+		for ; closing < len(segment); closing++ {
+
+			if quote != 0 {
+
+				if segment[closing] == quote {
+					quote = 0
+				}
+				continue
+			}
+
+			switch segment[closing] {
+			case '\'', '"':
+				quote = segment[closing]
+			case '(':
+				depth++
+			case ')':
+				depth--
+			}
+
+			if depth <= 0 {
+				return last, closing, last > first
+			}
+		}
+	}
+	return last, closing, false
+}
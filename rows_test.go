@@ -0,0 +1,124 @@
+package npq
+
+import (
+	"testing"
+)
+
+type RowTest struct {
+	A int    `sqlParameterName:"a"`
+	B string `sqlParameterName:"b"`
+}
+
+func TestExpandRows(test *testing.T) {
+
+	var prsr Parser
+	var err error
+
+	prsr = NewParser("INSERT INTO table (a, b, c) VALUES (:a, lower(:b), '(') ON CONFLICT (a) DO UPDATE SET c = :c")
+	prsr.SetValue("c", "lost")
+
+	err = prsr.ExpandRows([]RowTest{
+		{A: 1, B: "foo"},
+		{A: 2, B: "bar"},
+	})
+
+	if err != nil {
+		test.Log("Expected rows to be expanded. Actual: ", err)
+		test.FailNow()
+	}
+
+	prsr.SetValue("c", "baz")
+
+	if prsr.GetParsedQuery() != "INSERT INTO table (a, b, c) VALUES ($1, lower($2), '('), ($3, lower($4), '(') ON CONFLICT (a) DO UPDATE SET c = $5" {
+		test.Log("Expected the VALUES tuple to be replicated. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+
+	verifyStructParameters("ExpandRows", test, prsr, []interface{}{
+		1, "foo", 2, "bar", "baz",
+	})
+
+	if offsets := prsr.ParameterOffsets(); len(offsets["a"]) != 2 || offsets["a"][1] != 36 || offsets["c"][0] != 90 {
+		test.Log("Expected every row to refer to the offsets of the original tuple. Actual: ", offsets)
+		test.Fail()
+	}
+
+	if prsr.ParameterCount("a") != 2 {
+		test.Log("Expected a parameter for every row. Actual: ", prsr.ParameterCount("a"))
+		test.Fail()
+	}
+
+	// expanding again starts from the original query.
+	err = prsr.ExpandRows([]interface{}{
+		&RowTest{A: 3, B: "baz"},
+		map[string]interface{}{"a": 4},
+		map[string]interface{}{"b": "qux"},
+	})
+
+	if err != nil {
+		test.Log("Expected rows to be expanded again. Actual: ", err)
+		test.FailNow()
+	}
+
+	if prsr.GetParsedQuery() != "INSERT INTO table (a, b, c) VALUES ($1, lower($2), '('), ($3, lower($4), '('), ($5, lower($6), '(') ON CONFLICT (a) DO UPDATE SET c = $7" {
+		test.Log("Expected the VALUES tuple to be replicated from the original query. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+
+	verifyStructParameters("ExpandRowsAgain", test, prsr, []interface{}{
+		3, "baz", 4, nil, nil, "qux", nil,
+	})
+
+	if prsr.Validate() == nil {
+		test.Log("Expected the values missing from rows to be unset")
+		test.Fail()
+	}
+
+	// tuples without parameters before the first with one are kept as they are.
+	prsr = NewParser("INSERT INTO table (a, b) VALUES (1, '(2)'), (3, 4) , (:a, :b)")
+
+	err = prsr.ExpandRows([]RowTest{
+		{A: 1, B: "foo"},
+		{A: 2, B: "bar"},
+	})
+
+	if err != nil {
+		test.Log("Expected a later tuple to be expanded. Actual: ", err)
+		test.FailNow()
+	}
+
+	if prsr.GetParsedQuery() != "INSERT INTO table (a, b) VALUES (1, '(2)'), (3, 4) , ($1, $2), ($3, $4)" {
+		test.Log("Expected the first tuple containing a parameter to be replicated. Actual: ", prsr.GetParsedQuery())
+		test.Fail()
+	}
+
+	verifyStructParameters("ExpandLaterTuple", test, prsr, []interface{}{
+		1, "foo", 2, "bar",
+	})
+}
+
+func TestExpandRowsErrors(test *testing.T) {
+
+	var prsr Parser
+
+	prsr = NewParser("INSERT INTO table (a) VALUES (1); SELECT :a")
+
+	if prsr.ExpandRows([]RowTest{{A: 1}}) == nil {
+		test.Log("Expected an error for a query without a VALUES tuple containing parameters")
+		test.Fail()
+	}
+
+	prsr = NewParser("INSERT INTO table (a) VALUES (:a)")
+
+	if prsr.ExpandRows([]RowTest{}) == nil || prsr.ExpandRows(RowTest{}) == nil || prsr.ExpandRows([]int{1}) == nil {
+		test.Log("Expected an error for rows which are not a non-empty slice of structs or maps")
+		test.Fail()
+	}
+
+	prsr = NewParserWithOptions("INSERT INTO table (a) VALUES (:a)", WithDeduplication())
+
+	if prsr.ExpandRows([]RowTest{{A: 1}}) == nil {
+		test.Log("Expected an error for deduplicated parameters")
+		test.Fail()
+	}
+}
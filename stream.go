@@ -0,0 +1,313 @@
+package npq
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ParseStream parses the query read from the given [r] in the same way as
+// NewParser, but writes the revised query to the given [w] while it is read,
+// instead of keeping the original or revised query text, so that very large
+// scripts can be rewritten without holding them in memory. The returned parser
+// only records the parameters of the query, so that their values can be bound
+// and retrieved with GetParsedParameters as usual.
+//
+// Since the placeholders have already been written, the returned parser never
+// expands a slice value into multiple placeholders; slices are bound as single
+// array values, as by SetArrayValue. GetParsedQuery returns an empty string, and
+// ExpandRows returns an error. Quotes, comments, casts, escaped prefixes and
+// dollar quoted strings are handled in the same way as by NewParser, except that
+// no tokens are recorded for QuotedParameterLikeTokens, and a name in braces is
+// only recognized if it is at most 64 bytes long.
+//
+// An error is returned, together with the parameters parsed so far, if reading
+// from [r] or writing to [w] fails.
+func ParseStream(r io.Reader, w io.Writer) (Parser, error) {
+
+	var writer *bufio.Writer
+	var err error
+
+	p := newParser(DialectPostgres)
+	p.streamed = true
+
+	writer = bufio.NewWriter(w)
+	err = p.parseStream(bufio.NewReader(r), writer)
+
+	if err == nil {
+		err = writer.Flush()
+	}
+
+	p.parameters = make([]interface{}, len(p.positionNames))
+	p.assigned = make([]bool, len(p.positionNames))
+	p.expansions = make([][]interface{}, len(p.positionNames))
+
+	return p, err
+}
+
+// parseStream copies the query read from the given [reader] to the given [writer],
+// replacing every named parameter by its placeholder, and records the parameters
+// of p in the same way as setQuery. The first error reading or writing is returned.
+func (p *parser) parseStream(reader *bufio.Reader, writer *bufio.Writer) error {
+
+	var parameterBuilder bytes.Buffer
+	var character rune
+	var next rune
+	var width int
+	var offset int
+	var start int
+	var parameterName string
+	var braced string
+	var tag string
+	var err error
+
+	for {
+
+		character, width, err = reader.ReadRune()
+
+		if err != nil {
+			break
+		}
+
+		start = offset
+		offset += width
+
+		// a double colon is a Postgres type cast, and ":=" an assignment, not a parameter.
+		if character == ':' {
+
+			next = peekRune(reader, 0)
+
+			if next == ':' || next == '=' {
+
+				reader.ReadRune()
+				offset++
+
+				writer.WriteRune(character)
+				writer.WriteRune(next)
+				continue
+			}
+		}
+
+		// a backslash escaped prefix is a literal prefix character.
+		if character == '\\' && peekRune(reader, 0) == p.prefix {
+
+			reader.ReadRune()
+			offset += utf8.RuneLen(p.prefix)
+
+			writer.WriteRune(p.prefix)
+			continue
+		}
+
+		if character == p.prefix {
+
+			parameterBuilder.Reset()
+			braced = peekBracedName(reader)
+
+			if len(braced) > 0 {
+
+				reader.Discard(len(braced) + 2)
+				offset += len(braced) + 2
+
+				parameterBuilder.WriteString(braced)
+			}
+
+			for len(braced) <= 0 {
+
+				next = peekRune(reader, 0)
+
+				// combining marks may follow the first character, and a dot separates the parts
+				// of a nested name, but only if another part follows it.
+				if isNameCharacter(next) || (unicode.IsMark(next) && parameterBuilder.Len() > 0) || (next == '.' && parameterBuilder.Len() > 0 && isNameCharacter(peekRune(reader, 1))) {
+
+					reader.ReadRune()
+					offset += utf8.RuneLen(next)
+
+					parameterBuilder.WriteRune(next)
+					continue
+				}
+				break
+			}
+
+			// a prefix without a name is not a parameter, and is kept as a literal character.
+			if parameterBuilder.Len() <= 0 {
+				writer.WriteRune(p.prefix)
+				continue
+			}
+
+			parameterName = p.normalizeName(parameterBuilder.String())
+
+			p.positions.add(parameterName, len(p.positionNames))
+			p.occurrences = append(p.occurrences, len(p.positionNames))
+			p.offsets = append(p.offsets, start)
+			p.positionNames = append(p.positionNames, parameterName)
+
+			writer.WriteString(p.placeholder(len(p.positionNames)))
+			continue
+		}
+
+		// a Postgres dollar quoted string, e.g. "$$ ... $$", is copied verbatim.
+		if character == '$' && p.dialect.dollarQuotes() && p.prefix != '$' {
+
+			tag = peekDollarQuoteTag(reader)
+
+			if len(tag) > 0 {
+
+				writer.WriteString(tag)
+				reader.Discard(len(tag) - 1)
+				offset += len(tag) - 1
+
+				offset, err = copyUntil(reader, writer, tag, offset)
+
+				if err != nil {
+					break
+				}
+				continue
+			}
+		}
+
+		writer.WriteRune(character)
+
+		// quoted strings and identifiers are copied verbatim; a doubled quote does not end them.
+		if character == '\'' || character == '"' {
+
+			for {
+
+				offset, err = copyUntil(reader, writer, string(character), offset)
+
+				if err != nil || peekRune(reader, 0) != character {
+					break
+				}
+
+				reader.ReadRune()
+				offset += width
+				writer.WriteRune(character)
+			}
+
+			if err != nil {
+				break
+			}
+			continue
+		}
+
+		// comments are copied verbatim, up to the end of the line or the closing "*/".
+		if character == '-' && peekRune(reader, 0) == '-' {
+
+			for {
+
+				character, width, err = reader.ReadRune()
+
+				if err != nil {
+					break
+				}
+
+				offset += width
+				writer.WriteRune(character)
+
+				if character == '\n' || character == '\r' {
+					break
+				}
+			}
+
+			if err != nil {
+				break
+			}
+			continue
+		}
+
+		if character == '/' && peekRune(reader, 0) == '*' {
+
+			reader.ReadRune()
+			offset++
+			writer.WriteRune('*')
+
+			offset, err = copyUntil(reader, writer, "*/", offset)
+
+			if err != nil {
+				break
+			}
+		}
+	}
+
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+// peekRune returns the rune starting [skip] bytes ahead in the given [reader],
+// without reading it, or utf8.RuneError if there is none.
+func peekRune(reader *bufio.Reader, skip int) rune {
+
+	var peeked []byte
+	var character rune
+
+	peeked, _ = reader.Peek(skip + utf8.UTFMax)
+
+	if len(peeked) <= skip {
+		return utf8.RuneError
+	}
+
+	character, _ = utf8.DecodeRune(peeked[skip:])
+	return character
+}
+
+// peekDollarQuoteTag returns the tag of the dollar quoted string whose opening
+// "$" has just been read from the given [reader], as returned by dollarQuoteTag,
+// without reading the rest of it.
+func peekDollarQuoteTag(reader *bufio.Reader) string {
+
+	var peeked []byte
+
+	// tags are identifiers, and so are short enough to peek at once.
+	peeked, _ = reader.Peek(64)
+
+	return dollarQuoteTag("$" + string(peeked))
+}
+
+// peekBracedName returns the parameter name in braces following the prefix which
+// has just been read from the given [reader], as returned by bracedName, without
+// reading it.
+func peekBracedName(reader *bufio.Reader) string {
+
+	var peeked []byte
+
+	// names are identifiers, and so are short enough to peek at once.
+	peeked, _ = reader.Peek(66)
+
+	return bracedName(string(peeked))
+}
+
+// copyUntil copies everything read from the given [reader] to the given [writer],
+// up to and including the first occurrence of the given [terminator], and returns
+// the given [offset] advanced by the bytes read. An unterminated text runs to the
+// end of the reader, in which case io.EOF is returned.
+func copyUntil(reader *bufio.Reader, writer *bufio.Writer, terminator string, offset int) (int, error) {
+
+	var character byte
+	var matched int
+	var err error
+
+	for matched < len(terminator) {
+
+		character, err = reader.ReadByte()
+
+		if err != nil {
+			return offset, err
+		}
+
+		offset++
+		writer.WriteByte(character)
+
+		switch {
+		case character == terminator[matched]:
+			matched++
+		case character == terminator[0]:
+			matched = 1
+		default:
+			matched = 0
+		}
+	}
+	return offset, nil
+}
@@ -0,0 +1,98 @@
+package npq
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// failingWriter is an io.Writer which fails every write.
+type failingWriter struct{}
+
+func (failingWriter) Write(data []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestParseStream(test *testing.T) {
+
+	var prsr Parser
+	var streamed Parser
+	var output bytes.Buffer
+	var err error
+
+	queries := []string{
+		"SELECT * FROM table WHERE col1 = :foo AND col2 = :bar AND col3 = :foo",
+		"SELECT ':foo', \":bar\", 'it'':s' FROM table WHERE col1 = :baz::int -- :comment\nAND col2 = :user.name.",
+		"SELECT /* :foo */ :bar, $$ :baz $$, $body$ :qux $$ $body$, x := :naïve, \\:escaped, : , :",
+		"SELECT * FROM table WHERE col1 = 'unterminated :foo",
+		"SELECT * FROM table_:{id}s WHERE col1 = :{user.name}x AND col2 = :{} AND col3 = :{a",
+		strings.Repeat("SELECT :a, ':b', -- :c\n", 500) + "SELECT '" + strings.Repeat(":d", 5000) + "'",
+	}
+
+	for _, query := range queries {
+
+		output.Reset()
+		prsr = NewParser(query)
+		streamed, err = ParseStream(strings.NewReader(query), &output)
+
+		if err != nil {
+			test.Log("Expected no error streaming a query. Actual: ", err)
+			test.Fail()
+			continue
+		}
+
+		if output.String() != prsr.GetParsedQuery() {
+			test.Log("Expected the streamed query to match the parsed query. Actual: ", output.String())
+			test.Fail()
+		}
+
+		if strings.Join(streamed.ParameterNames(), ",") != strings.Join(prsr.ParameterNames(), ",") || streamed.NumParameters() != prsr.NumParameters() {
+			test.Log("Expected the streamed parameters to match the parsed parameters. Actual: ", streamed.ParameterNames())
+			test.Fail()
+		}
+
+		if streamed.GetParsedQuery() != "" {
+			test.Log("Expected a streamed parser not to keep the revised query. Actual: ", streamed.GetParsedQuery())
+			test.Fail()
+		}
+	}
+}
+
+func TestParseStreamValues(test *testing.T) {
+
+	var prsr Parser
+	var output bytes.Buffer
+	var err error
+
+	prsr, _ = ParseStream(strings.NewReader("SELECT * FROM table WHERE col1 = :foo AND col2 = ANY(:ids) AND col3 = :foo"), &output)
+	prsr.SetValue("foo", "foo")
+	prsr.SetValue("ids", []int{1, 2, 3})
+
+	if output.String() != "SELECT * FROM table WHERE col1 = $1 AND col2 = ANY($2) AND col3 = $3" {
+		test.Log("Unexpected streamed query: ", output.String())
+		test.Fail()
+	}
+
+	if len(prsr.GetParsedParameters()) != 3 || prsr.GetParsedParameters()[0] != "foo" || prsr.GetParsedParameters()[2] != "foo" {
+		test.Log("Expected a slice value not to be expanded. Actual: ", prsr.GetParsedParameters())
+		test.Fail()
+	}
+
+	if positions := prsr.ParameterOffsets()["ids"]; len(positions) != 1 || positions[0] != 53 {
+		test.Log("Expected the offsets of the streamed parameters. Actual: ", positions)
+		test.Fail()
+	}
+
+	if prsr.ExpandRows([]map[string]interface{}{{"foo": 1}}) == nil {
+		test.Log("Expected an error expanding rows of a streamed query")
+		test.Fail()
+	}
+
+	_, err = ParseStream(strings.NewReader("SELECT :foo"), failingWriter{})
+
+	if err == nil {
+		test.Log("Expected an error for a failing writer")
+		test.Fail()
+	}
+}
@@ -0,0 +1,323 @@
+package npq
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SetValuesFromStruct uses reflection to find every public field of the given struct [parameters]
+// and set their key/value as named parameters in p query.
+// If the given [parameters] is neither a struct nor a non-nil pointer to a struct, p will return an error.
+//
+// If you do not wish for a field in the struct to be added by its literal name,
+// The struct may optionally specify the sqlParameterName as a tag on the field.
+// e.g., a struct field may say something like:
+//
+// 	type Test struct {
+// 		Foo string `sqlParameterName:"foobar"`
+// 	}
+//
+// The fields of an embedded (anonymous) struct are bound as if they were fields
+// of the outer struct, unless the embedded struct itself has a sqlParameterName tag,
+// in which case it is bound as a single value under that name.
+//
+// A field tagged with `sqlParameterName:"-"` is skipped entirely.
+//
+// The fields of a nested struct can be referenced with dotted parameter names,
+// so that ":User.Name" is bound to the Name field of the User field. Each part of
+// a dotted name is matched in the same way as a top level field name or tag.
+//
+// Pointer fields are bound to the value they point to, or to nil if the pointer is nil.
+//
+// A time.Time field may specify a layout in a sqlTimeFormat tag, in which case
+// it is bound as a string formatted with that layout (see time.Time.Format):
+//
+// 	type Test struct {
+// 		Day time.Time `sqlTimeFormat:"2006-01-02"`
+// 	}
+//
+// A field may instead name a method of its struct in a sqlParameterSource tag, in
+// which case it is bound to the value returned by that method, so that derived
+// values can be bound along with the fields they are computed from:
+//
+// 	type Test struct {
+// 		First    string
+// 		Last     string
+// 		FullName string `sqlParameterSource:"Name"`
+// 	}
+//
+// 	func (t Test) Name() string {
+// 		return t.First + " " + t.Last
+// 	}
+//
+// The method must take no arguments and return a single value, otherwise an error
+// is returned. Methods with a pointer receiver are only found if [parameters] is a
+// pointer to the struct.
+//
+// If p was created with WithJSONTagFallback, a field without a sqlParameterName
+// tag is named by its json tag, if it has one, before falling back to its name.
+//
+// If p was created with WithDuplicateFieldCheck, an error is returned and nothing
+// is bound if two fields would bind the same parameter name.
+func (p *parser) SetValuesFromStruct(parameters interface{}) error {
+	return p.setValuesFromStruct(parameters, parameterNameTag)
+}
+
+// SetValuesFromStructWithTag sets the values of p query from the fields of the
+// given struct [parameters] in the same way as SetValuesFromStruct, but reads
+// parameter names from the tag with the given [tagName] instead of the
+// sqlParameterName tag, e.g. "db" to reuse the tags of sqlx:
+//
+// 	type Test struct {
+// 		Foo string `db:"foobar"`
+// 	}
+//
+// Any options following a comma in the tag, e.g. in `db:"foobar,omitempty"`, are ignored.
+func (p *parser) SetValuesFromStructWithTag(parameters interface{}, tagName string) error {
+	return p.setValuesFromStruct(parameters, tagName)
+}
+
+// parameterNameTag is the struct tag which names the parameter of a field by default.
+const parameterNameTag = "sqlParameterName"
+
+// parameterSourceTag is the struct tag which names the method a field's value is returned by.
+const parameterSourceTag = "sqlParameterSource"
+
+// setValuesFromStruct implements SetValuesFromStruct, reading parameter names
+// from the tag with the given [tagName].
+func (p *parser) setValuesFromStruct(parameters interface{}, tagName string) error {
+
+	var fieldValues reflect.Value
+	var bindings []structBinding
+	var fields map[string]string
+	var name string
+	var err error
+
+	fieldValues = reflect.ValueOf(parameters)
+
+	if fieldValues.Kind() == reflect.Ptr {
+
+		if fieldValues.IsNil() {
+			return errors.New("Unable to add query values from parameter: parameter is a nil pointer")
+		}
+		fieldValues = fieldValues.Elem()
+	}
+
+	if fieldValues.Kind() != reflect.Struct {
+		return errors.New("Unable to add query values from parameter: parameter is not a struct")
+	}
+
+	bindings, err = p.collectStructValues(fieldValues, tagName, "", "", nil)
+
+	if err != nil {
+		return err
+	}
+
+	if p.checkDuplicateFields {
+
+		fields = make(map[string]string, len(bindings))
+
+		for _, binding := range bindings {
+
+			name = p.normalizeName(binding.name)
+
+			if field, exists := fields[name]; exists {
+				return fmt.Errorf("Unable to add query values from parameter: fields %s and %s both bind parameter %s%s", field, binding.field, string(p.prefix), binding.name)
+			}
+			fields[name] = binding.field
+		}
+	}
+
+	for _, binding := range bindings {
+		p.SetValue(binding.name, binding.value)
+	}
+	return nil
+}
+
+// structBinding is a single parameter value collected from a struct field.
+type structBinding struct {
+
+	// The path of the field the value was taken from, e.g. "User.Name".
+	field string
+
+	// The name of the parameter the value is bound to.
+	name string
+
+	value interface{}
+}
+
+// collectStructValues appends a binding for every public field of the given struct
+// value to [bindings], recursing into embedded structs. The name of every field is
+// read from its tag with the given [tagName], and prefixed with the given
+// [namePrefix], and struct fields are recursed into if p query has a nested
+// parameter name starting with theirs. [fieldPrefix] is the path of the struct
+// value within the struct passed to SetValuesFromStruct. An error is returned if a
+// field names a method in its sqlParameterSource tag which cannot be called.
+func (p *parser) collectStructValues(fieldValues reflect.Value, tagName string, namePrefix string, fieldPrefix string, bindings []structBinding) ([]structBinding, error) {
+
+	var fieldValue reflect.Value
+	var nestedValue reflect.Value
+	var parameterType reflect.Type
+	var parameterField reflect.StructField
+	var queryTag string
+	var sourceTag string
+	var timeFormat string
+	var value interface{}
+	var visibilityCharacter rune
+	var err error
+
+	parameterType = fieldValues.Type()
+
+	for i := 0; i < fieldValues.NumField(); i++ {
+
+		fieldValue = fieldValues.Field(i)
+		parameterField = parameterType.Field(i)
+
+		// check to see if p has a tag indicating a different query name
+		queryTag = parameterField.Tag.Get(tagName)
+
+		// ignore tag options, e.g. ",omitempty"
+		if comma := strings.IndexByte(queryTag, ','); comma >= 0 {
+			queryTag = queryTag[:comma]
+		}
+
+		// explicitly excluded from binding?
+		if queryTag == "-" {
+			continue
+		}
+
+		// fall back to the json name, if requested. A field excluded from json is still bound.
+		if len(queryTag) <= 0 && p.jsonTagFallback {
+
+			queryTag = parameterField.Tag.Get("json")
+
+			if comma := strings.IndexByte(queryTag, ','); comma >= 0 {
+				queryTag = queryTag[:comma]
+			}
+
+			if queryTag == "-" {
+				queryTag = ""
+			}
+		}
+
+		// embedded struct? bind its fields as our own.
+		if parameterField.Anonymous && fieldValue.Kind() == reflect.Struct && len(queryTag) <= 0 {
+			bindings, err = p.collectStructValues(fieldValue, tagName, namePrefix, fieldPrefix+parameterField.Name+".", bindings)
+
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// public field?
+		visibilityCharacter, _ = utf8.DecodeRuneInString(parameterField.Name[0:])
+
+		if fieldValue.CanSet() || unicode.IsUpper(visibilityCharacter) {
+
+			// otherwise just add the struct's name.
+			if len(queryTag) <= 0 {
+				queryTag = parameterField.Name
+			}
+
+			queryTag = namePrefix + queryTag
+			value = derefValue(fieldValue)
+
+			// derived from a method of the struct? bind its result instead.
+			sourceTag = parameterField.Tag.Get(parameterSourceTag)
+
+			if len(sourceTag) > 0 {
+
+				value, err = sourceValue(fieldValues, sourceTag, fieldPrefix+parameterField.Name)
+
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			// nested struct referenced as ":field.name"? bind its fields too.
+			nestedValue = reflect.ValueOf(value)
+
+			if nestedValue.Kind() == reflect.Struct && p.hasNestedNames(queryTag) {
+				bindings, err = p.collectStructValues(nestedValue, tagName, queryTag+".", fieldPrefix+parameterField.Name+".", bindings)
+
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			// format times as strings, if requested.
+			timeFormat = parameterField.Tag.Get("sqlTimeFormat")
+
+			if timeValue, isTime := value.(time.Time); isTime && len(timeFormat) > 0 {
+				value = timeValue.Format(timeFormat)
+			}
+
+			bindings = append(bindings, structBinding{
+				field: fieldPrefix + parameterField.Name,
+				name:  queryTag,
+				value: value,
+			})
+		}
+	}
+	return bindings, nil
+}
+
+// sourceValue returns the value returned by the method with the given [methodName]
+// of the given [structValue], or of a pointer to it if it is addressable, as named
+// by the sqlParameterSource tag of the given [field]. An error is returned if there
+// is no such method, or if it takes any arguments or does not return a single value.
+func sourceValue(structValue reflect.Value, methodName string, field string) (interface{}, error) {
+
+	var method reflect.Value
+
+	method = structValue.MethodByName(methodName)
+
+	if !method.IsValid() && structValue.CanAddr() {
+		method = structValue.Addr().MethodByName(methodName)
+	}
+
+	if !method.IsValid() {
+		return nil, fmt.Errorf("Unable to add query values from parameter: field %s names method %s, which does not exist", field, methodName)
+	}
+
+	if method.Type().NumIn() != 0 || method.Type().NumOut() != 1 {
+		return nil, fmt.Errorf("Unable to add query values from parameter: method %s of field %s must take no arguments and return a single value", methodName, field)
+	}
+
+	return derefValue(method.Call(nil)[0]), nil
+}
+
+// hasNestedNames returns true if p query has any parameter nested below the given
+// [parameterName], i.e. one whose name starts with [parameterName] followed by a dot.
+func (p *parser) hasNestedNames(parameterName string) bool {
+
+	parameterName = p.normalizeName(parameterName)
+
+	for _, name := range p.positions.names {
+		if strings.HasPrefix(name, parameterName+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// derefValue returns the value pointed to by the given [value] if it is a pointer,
+// or nil if it is a nil pointer, so that optional fields are bound as SQL NULL.
+// Any other value is returned as is.
+func derefValue(value reflect.Value) interface{} {
+
+	for value.Kind() == reflect.Ptr {
+
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	return value.Interface()
+}
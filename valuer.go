@@ -0,0 +1,127 @@
+package npq
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+)
+
+// resolveValuer returns the result of the Value method of the given [value] if it
+// implements driver.Valuer, or the value itself otherwise. A nil pointer is
+// resolved to nil instead of calling its method, as database/sql does. If Value
+// returns an error, the value is returned unchanged, so that the error is
+// reported by the driver when the query is executed.
+func resolveValuer(value interface{}) interface{} {
+
+	var valuer driver.Valuer
+	var reflectValue reflect.Value
+	var resolved driver.Value
+	var err error
+	var ok bool
+
+	valuer, ok = value.(driver.Valuer)
+	if !ok {
+		return value
+	}
+
+	reflectValue = reflect.ValueOf(value)
+	if reflectValue.Kind() == reflect.Ptr && reflectValue.IsNil() {
+		return nil
+	}
+
+	resolved, err = valuer.Value()
+	if err != nil {
+		return value
+	}
+
+	return resolved
+}
+
+// nullValue returns the value contained in the given [value] if it is one of the
+// standard sql.NullString, sql.NullInt64, sql.NullBool, sql.NullFloat64 or
+// sql.NullTime types, or nil if it is not valid. Any other value is returned
+// unchanged.
+func nullValue(value interface{}) interface{} {
+
+	switch null := value.(type) {
+	case sql.NullString:
+		if null.Valid {
+			return null.String
+		}
+	case sql.NullInt64:
+		if null.Valid {
+			return null.Int64
+		}
+	case sql.NullBool:
+		if null.Valid {
+			return null.Bool
+		}
+	case sql.NullFloat64:
+		if null.Valid {
+			return null.Float64
+		}
+	case sql.NullTime:
+		if null.Valid {
+			return null.Time
+		}
+	default:
+		return value
+	}
+	return nil
+}
+
+// underlyingValue returns the given [value] converted to the built-in type of its
+// kind if it is of a named boolean, numeric or string type, e.g. an int for a value
+// of "type Status int", or the value itself otherwise. Values implementing
+// driver.Valuer are never converted, so that the driver still calls their method.
+func underlyingValue(value interface{}) interface{} {
+
+	var reflectValue reflect.Value
+
+	if value == nil {
+		return nil
+	}
+
+	if _, ok := value.(driver.Valuer); ok {
+		return value
+	}
+
+	reflectValue = reflect.ValueOf(value)
+
+	// built-in types have no package path, and need no conversion.
+	if len(reflectValue.Type().PkgPath()) <= 0 {
+		return value
+	}
+
+	switch reflectValue.Kind() {
+	case reflect.Bool:
+		return reflectValue.Bool()
+	case reflect.Int:
+		return int(reflectValue.Int())
+	case reflect.Int8:
+		return int8(reflectValue.Int())
+	case reflect.Int16:
+		return int16(reflectValue.Int())
+	case reflect.Int32:
+		return int32(reflectValue.Int())
+	case reflect.Int64:
+		return reflectValue.Int()
+	case reflect.Uint:
+		return uint(reflectValue.Uint())
+	case reflect.Uint8:
+		return uint8(reflectValue.Uint())
+	case reflect.Uint16:
+		return uint16(reflectValue.Uint())
+	case reflect.Uint32:
+		return uint32(reflectValue.Uint())
+	case reflect.Uint64:
+		return reflectValue.Uint()
+	case reflect.Float32:
+		return float32(reflectValue.Float())
+	case reflect.Float64:
+		return reflectValue.Float()
+	case reflect.String:
+		return reflectValue.String()
+	}
+	return value
+}